@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"fmt"
+	"main/model"
+	"os"
+)
+
+/*
+Sends the moderation command from the config to the game at the join 
+address and prints the hosts answer. Everything is taken from the config, 
+nothing is prompted.
+*/
+func Admin(config Config) {
+	transport := configTransport(config)
+	if config.TLS {
+		transport.SetTLS(model.ClientTLSConfig(config.Pin))
+	}
+	network := new(model.Network)
+	network.SetTransport(transport)
+	connErr := network.DialHost(config.Join)
+	if connErr != nil {
+		fmt.Println("could not reach the host", connErr)
+		os.Exit(1)
+	}
+	accepted, reason, adminErr := network.Admin(config.AdminPassword, config.Command)
+	if adminErr != nil {
+		fmt.Println("lost the connection to the host", adminErr)
+		os.Exit(1)
+	}
+	if !accepted {
+		fmt.Println("the host refused the command:", reason)
+		os.Exit(1)
+	}
+	fmt.Println("the host ran", config.Command)
+}
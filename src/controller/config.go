@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"main/model"
+	"net"
+	"os"
+	"strconv"
+)
+
+/*
+Settings for hosting and joining games. Values are read from an optional
+JSON config file and then overridden by command-line flags, they are used
+as the defaults of the menu prompts.
+*/
+type Config struct {
+	Host          string `json:"host"`
+	Port          string `json:"port"`
+	Transport     string `json:"transport"`
+	Path          string `json:"path"`
+	Join          string `json:"join"`
+	TurnSeconds   int    `json:"turnSeconds"`
+	JudgeSeconds  int    `json:"judgeSeconds"`
+	BotCover      bool   `json:"botCover"`
+	Heartbeat     int    `json:"heartbeatSeconds"`
+	MaxMissed     int    `json:"maxMissed"`
+	Lobby         bool   `json:"lobby"`
+	Server        bool   `json:"server"`
+	Start         string `json:"start"`
+	TLS           bool   `json:"tls"`
+	Cert          string `json:"cert"`
+	Key           string `json:"key"`
+	Pin           string `json:"pin"`
+	Password      string `json:"password"`
+	Invite        bool   `json:"invite"`
+	AdminPassword string `json:"adminPassword"`
+	Command       string `json:"command"`
+	Discovery     int    `json:"discoveryPort"`
+}
+
+/*
+How a dedicated server decides to start the game.
+*/
+const (
+	START_VOTE  = "vote"
+	START_FIRST = "first"
+)
+
+/*
+Returns the configuration used when no file or flags are given.
+*/
+func DefaultConfig() Config {
+	return Config{
+		Host:         model.CONN_HOST,
+		Port:         model.CONN_PORT,
+		Transport:    "tcp",
+		Path:         model.WS_PATH,
+		Join:         net.JoinHostPort(model.CONN_HOST, model.CONN_PORT),
+		TurnSeconds:  DEFAULT_TURN_SECONDS,
+		JudgeSeconds: DEFAULT_JUDGE_SECONDS,
+		BotCover:     true,
+		Heartbeat:    DEFAULT_HEARTBEAT_SECONDS,
+		MaxMissed:    DEFAULT_MAX_MISSED,
+		Start:        START_VOTE,
+		Discovery:    model.DISCOVERY_PORT,
+	}
+}
+
+/*
+Builds the configuration from the command-line arguments. A config file
+given with -config is applied first and flags that are set explicitly
+override it.
+
+Returns an error if the arguments, the file or the resulting values are
+invalid.
+*/
+func LoadConfig(args []string) (Config, error) {
+	config := DefaultConfig()
+	var flagConfig Config
+	var configPath string
+
+	flags := flag.NewFlagSet("apples2apples", flag.ContinueOnError)
+	flags.StringVar(&configPath, "config", "", "path to a JSON config file")
+	flags.StringVar(&flagConfig.Host, "host", config.Host, "address to listen on when hosting")
+	flags.StringVar(&flagConfig.Port, "port", config.Port, "port to listen on when hosting, 0 picks a free port")
+	flags.StringVar(&flagConfig.Transport, "transport", config.Transport, "connection type, websocket or tcp")
+	flags.StringVar(&flagConfig.Path, "path", config.Path, "HTTP path of the WebSocket endpoint")
+	flags.StringVar(&flagConfig.Join, "join", config.Join, "host:port to join")
+	flags.IntVar(&flagConfig.TurnSeconds, "turn", config.TurnSeconds, "seconds to play a card, 0 for no limit")
+	flags.IntVar(&flagConfig.JudgeSeconds, "judge", config.JudgeSeconds, "seconds to judge, 0 for no limit")
+	flags.BoolVar(&flagConfig.BotCover, "cover", config.BotCover, "let bots play for disconnected players")
+	flags.IntVar(&flagConfig.Heartbeat, "heartbeat", config.Heartbeat, "seconds between pings to players, 0 turns pings off")
+	flags.IntVar(&flagConfig.MaxMissed, "misses", config.MaxMissed, "unanswered pings before a player is considered lost")
+	flags.BoolVar(&flagConfig.Lobby, "lobby", config.Lobby, "run a lobby server that hosts a game in every room players create")
+	flags.BoolVar(&flagConfig.Server, "server", config.Server, "run a dedicated server for one game without a host player")
+	flags.StringVar(&flagConfig.Start, "start", config.Start, "when a dedicated server starts the game, vote or first")
+	flags.BoolVar(&flagConfig.TLS, "tls", config.TLS, "encrypt connections with TLS, wss for the WebSocket transport")
+	flags.StringVar(&flagConfig.Cert, "cert", config.Cert, "PEM certificate to host with, a self-signed one is generated if empty")
+	flags.StringVar(&flagConfig.Key, "key", config.Key, "PEM private key of the certificate")
+	flags.StringVar(&flagConfig.Pin, "pin", config.Pin, "fingerprint of the host certificate to trust when joining")
+	flags.StringVar(&flagConfig.Password, "password", config.Password, "password players have to enter to join, or to send when joining")
+	flags.BoolVar(&flagConfig.Invite, "invite", config.Invite, "generate an invite code players have to enter to join")
+	flags.StringVar(&flagConfig.AdminPassword, "admin", config.AdminPassword, "password for remote moderation commands, empty turns them off")
+	flags.StringVar(&flagConfig.Command, "command", config.Command, "send a moderation command with the admin password to the game at -join and exit")
+	flags.IntVar(&flagConfig.Discovery, "discovery", config.Discovery, "UDP port to announce and find games on the local network, 0 turns discovery off")
+	parseErr := flags.Parse(args)
+	if parseErr != nil {
+		return config, parseErr
+	}
+
+	if configPath != "" {
+		data, readErr := os.ReadFile(configPath)
+		if readErr != nil {
+			return config, readErr
+		}
+		jsonErr := json.Unmarshal(data, &config)
+		if jsonErr != nil {
+			return config, errors.New("invalid config file, " + jsonErr.Error())
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			config.Host = flagConfig.Host
+		case "port":
+			config.Port = flagConfig.Port
+		case "transport":
+			config.Transport = flagConfig.Transport
+		case "path":
+			config.Path = flagConfig.Path
+		case "join":
+			config.Join = flagConfig.Join
+		case "turn":
+			config.TurnSeconds = flagConfig.TurnSeconds
+		case "judge":
+			config.JudgeSeconds = flagConfig.JudgeSeconds
+		case "cover":
+			config.BotCover = flagConfig.BotCover
+		case "heartbeat":
+			config.Heartbeat = flagConfig.Heartbeat
+		case "misses":
+			config.MaxMissed = flagConfig.MaxMissed
+		case "lobby":
+			config.Lobby = flagConfig.Lobby
+		case "server":
+			config.Server = flagConfig.Server
+		case "start":
+			config.Start = flagConfig.Start
+		case "tls":
+			config.TLS = flagConfig.TLS
+		case "cert":
+			config.Cert = flagConfig.Cert
+		case "key":
+			config.Key = flagConfig.Key
+		case "pin":
+			config.Pin = flagConfig.Pin
+		case "password":
+			config.Password = flagConfig.Password
+		case "invite":
+			config.Invite = flagConfig.Invite
+		case "admin":
+			config.AdminPassword = flagConfig.AdminPassword
+		case "command":
+			config.Command = flagConfig.Command
+		case "discovery":
+			config.Discovery = flagConfig.Discovery
+		}
+	})
+	return config, config.validate()
+}
+
+/*
+Checks that the configured values can be used.
+*/
+func (c Config) validate() error {
+	if c.Transport != "websocket" && c.Transport != "tcp" {
+		return errors.New("transport must be websocket or tcp")
+	}
+	port, portErr := strconv.Atoi(c.Port)
+	if portErr != nil || port < 0 || port > 65535 {
+		return errors.New("port must be a number between 0 and 65535")
+	}
+	if c.Discovery < 0 || c.Discovery > 65535 {
+		return errors.New("discovery port must be a number between 0 and 65535")
+	}
+	if c.TurnSeconds < 0 || c.JudgeSeconds < 0 {
+		return errors.New("time limits can not be negative")
+	}
+	if c.Heartbeat < 0 {
+		return errors.New("heartbeat can not be negative")
+	}
+	if c.MaxMissed < 1 {
+		return errors.New("misses must be at least 1")
+	}
+	if c.Start != START_VOTE && c.Start != START_FIRST {
+		return errors.New("start must be vote or first")
+	}
+	if c.Lobby && c.Server {
+		return errors.New("choose either lobby or server")
+	}
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("cert and key must be given together")
+	}
+	if !c.TLS && (c.Cert != "" || c.Pin != "") {
+		return errors.New("cert and pin are only used with tls")
+	}
+	if c.Invite && c.Password != "" {
+		return errors.New("choose either password or invite")
+	}
+	if c.Command != "" && c.AdminPassword == "" {
+		return errors.New("command needs the admin password")
+	}
+	return nil
+}
+
+/*
+Returns the address to listen on when hosting.
+*/
+func (c Config) ListenAddress() string {
+	return net.JoinHostPort(c.Host, c.Port)
+}
@@ -0,0 +1,74 @@
+package controller_test
+
+import (
+	"main/controller"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := controller.LoadConfig([]string{})
+	if err != nil {
+		t.Log("unexpected error:", err)
+		t.FailNow()
+	}
+	if config != controller.DefaultConfig() {
+		t.Log("expected the default config without arguments")
+		t.FailNow()
+	}
+}
+
+func TestLoadConfigFileAndFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeErr := os.WriteFile(path, []byte(`{"host": "0.0.0.0", "port": "9000", "transport": "websocket"}`), 0o644)
+	if writeErr != nil {
+		t.Log("incorrect test config,", writeErr)
+		t.FailNow()
+	}
+
+	config, err := controller.LoadConfig([]string{"-config", path, "-port", "0", "-join", "10.0.0.2:9000"})
+	if err != nil {
+		t.Log("unexpected error:", err)
+		t.FailNow()
+	}
+	if config.Host != "0.0.0.0" || config.Transport != "websocket" {
+		t.Log("expected values from the config file", config)
+		t.FailNow()
+	}
+	if config.Port != "0" || config.Join != "10.0.0.2:9000" {
+		t.Log("expected flags to override the config file", config)
+		t.FailNow()
+	}
+	if config.ListenAddress() != "0.0.0.0:0" {
+		t.Log("unexpected listen address", config.ListenAddress())
+		t.FailNow()
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	invalid := [][]string{
+		{"-port", "http"},
+		{"-port", "70000"},
+		{"-transport", "udp"},
+		{"-turn", "-1"},
+		{"-heartbeat", "-1"},
+		{"-misses", "0"},
+		{"-start", "later"},
+		{"-lobby", "-server"},
+		{"-tls", "-cert", "host.pem"},
+		{"-pin", "AB:CD"},
+		{"-invite", "-password", "secret"},
+		{"-command", "pause"},
+		{"-discovery", "70000"},
+		{"-config", filepath.Join(t.TempDir(), "missing.json")},
+		{"-unknown"},
+	}
+	for i := 0; i < len(invalid); i++ {
+		_, err := controller.LoadConfig(invalid[i])
+		if err == nil {
+			t.Log("did not catch invalid arguments", invalid[i])
+			t.FailNow()
+		}
+	}
+}
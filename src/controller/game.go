@@ -2,10 +2,13 @@ package controller
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"main/model"
 	"main/view"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,7 +16,17 @@ import (
 	"time"
 )
 
-func Game() {
+const (
+	DEFAULT_TURN_SECONDS = 60
+	DEFAULT_JUDGE_SECONDS = 90
+	RECONNECT_ATTEMPTS = 10
+	RECONNECT_DELAY = 2 * time.Second
+	MIGRATION_ATTEMPTS = 3
+	DEFAULT_HEARTBEAT_SECONDS = 5
+	DEFAULT_MAX_MISSED = 3
+)
+
+func Game(config Config) {
 	terminal := view.Terminal()
 	view.Greeting(terminal)
 	for terminal.Scan() {
@@ -23,20 +36,34 @@ func Game() {
 			board := setupOfflineGame(terminal)
 			playGame(terminal, board)
 		case "2":
-			board := setupOnlineGame(terminal)
+			ctx, stopListener := context.WithCancel(context.Background())
+			board := setupOnlineGame(ctx, terminal, config)
 			playGame(terminal, board)
+			stopListener()
 		case "3":
-			network, connErr := joinGame(terminal)
+			network, connErr := joinGame(terminal, config)
 			if connErr != nil {
+				network.Leave()
 				fmt.Println(connErr)
-				Game()
+				Game(config)
+				return
 			}
-			gameErr := playOnlineGame(&network)
+			gameErr := playOnlineGame(network, false)
 			if gameErr != nil {
-				panic(gameErr)
+				network.Leave()
+				fmt.Println(gameErr)
+				Game(config)
+				return
 			}
-
+			os.Exit(0)
 		case "4":
+			lobbyErr := joinLobby(terminal, config)
+			if lobbyErr != nil {
+				fmt.Println(lobbyErr)
+				Game(config)
+			}
+
+		case "5":
 			os.Exit(0)
 		default:
 			fmt.Println("Please select one of the options")
@@ -60,6 +87,7 @@ func setupOfflineGame(terminal bufio.Scanner) *model.Board {
 	=======================================================================
 	*/
 	board := new(model.Board)
+	watchGame(board, playerName)
 	board.AddPlayer(*model.NewPlayer(playerName, true, false, 7))
 	for i := 0; i < 3; i++ {
 		board.AddPlayer(*model.NewPlayer("Bot"+fmt.Sprint(i), false, true, 7))
@@ -152,7 +180,7 @@ func setupOfflineGame(terminal bufio.Scanner) *model.Board {
 }
 
 
-func setupOnlineGame(terminal bufio.Scanner) *model.Board {
+func setupOnlineGame(ctx context.Context, terminal bufio.Scanner, config Config) *model.Board {
 	/*
 	Prompt the player for a player name
 	=======================================================================
@@ -170,17 +198,52 @@ func setupOnlineGame(terminal bufio.Scanner) *model.Board {
 	onlinePlayers := view.OnlinePlayers(terminal)
 	
 	network := new(model.Network)
-	go network.Listener()
+	transport := chooseTransport(terminal, config)
+	secureHost(terminal, config, transport)
+	network.SetTransport(transport)
+	network.SetHeartbeat(time.Duration(config.Heartbeat) * time.Second, config.MaxMissed)
+	network.ReserveName(playerName)
+	for i := 0; i < 4; i++ {
+		network.ReserveName("Bot" + fmt.Sprint(i))
+	}
+	protectGame(terminal, config, network)
+	bindHost, bindPort := view.BindAddress(terminal, config.Host, config.Port)
+	address, listenErr := network.Listener(ctx, net.JoinHostPort(bindHost, bindPort))
+	if listenErr != nil {
+		fmt.Println("could not host the game ", listenErr)
+		panic(listenErr)
+	}
 	
-	fmt.Println("Waiting for players to connect to loaclhost, port 8080...")
-	for {
-		time.Sleep(1 * time.Second)
-		if network.CountOnlinePlayers() == int(onlinePlayers) {
-			break
+	network.SetCapacity(int(onlinePlayers))
+	announceGame(ctx, network, config, playerName + "'s game", address, func(err error) {
+		view.Announce("could not announce the game on the local network " + err.Error())
+	})
+	fmt.Println("Waiting for players to connect to", address.String(), "over", network.Transport().Name() + "...")
+	events := network.Events()
+	for network.CountOnlinePlayers() < int(onlinePlayers) {
+		event := <-events
+		switch event.Type {
+		case model.EVENT_PLAYER_JOINED:
+			fmt.Println(event.PlayerName, "joined,", network.CountOnlinePlayers(), "players connected...")
+		case model.EVENT_PLAYER_LEFT:
+			fmt.Println(event.PlayerName, "left,", network.CountOnlinePlayers(), "players connected...")
+		case model.EVENT_PLAYER_LOST:
+			fmt.Println(event.PlayerName, "stopped answering pings")
+		case model.EVENT_SPECTATOR_JOINED:
+			fmt.Println(event.PlayerName, "is watching,", network.CountSpectators(), "spectators")
+		case model.EVENT_CHAT:
+			view.Chat(event.PlayerName, time.Now(), event.Text)
+		case model.EVENT_SPECTATOR_LEFT:
+			fmt.Println(event.PlayerName, "stopped watching,", network.CountSpectators(), "spectators")
+		case model.EVENT_JOIN_REFUSED:
+			fmt.Println(event.PlayerName, "could not join,", event.Err)
+		case model.EVENT_ACCEPT_ERROR:
+			fmt.Println("could not accept connection ", event.Err)
 		}
-		fmt.Println(network.CountOnlinePlayers(), "players connected...")
 	}
+	network.StartGame()
 	fmt.Println("All players connected!")
+	go watchNetwork(ctx, network)
 	
 	
 
@@ -189,11 +252,12 @@ func setupOnlineGame(terminal bufio.Scanner) *model.Board {
 	=======================================================================
 	*/
 	board := new(model.Board)
+	watchGame(board, playerName)
 	board.AddPlayer(*model.NewPlayer(playerName, true, false, 7))
 
 	onlinePlayerNames := network.ListPlayers()
 	for i := 0; i < int(onlinePlayers); i++ {
-		board.AddPlayer(*model.NewPlayer(onlinePlayerNames[i], false, false, 7))
+		seatOnlinePlayer(board, network, onlinePlayerNames[i])
 	}
 
 	humanPlayers := board.CountPlayers()
@@ -202,10 +266,15 @@ func setupOnlineGame(terminal bufio.Scanner) *model.Board {
 	}
 
 	/*
-	Add network component to board.
+	Add network component and turn timers to board.
 	=======================================================================
 	*/
-	board.SetNetwork(*network)
+	board.SetNetwork(network)
+	turnTimeout := view.TimeLimit(terminal, "playing a card", config.TurnSeconds)
+	judgeTimeout := view.TimeLimit(terminal, "judging", config.JudgeSeconds)
+	board.SetTimers(turnTimeout, judgeTimeout)
+	board.SetBotCover(config.BotCover)
+	hostControls(board, network, playerName, config.AdminPassword)
 
 	/*
 	Load the card decks and add them to the board.
@@ -294,65 +363,161 @@ func setupOnlineGame(terminal bufio.Scanner) *model.Board {
 }
 
 func playGame(terminal bufio.Scanner, board *model.Board) {
-	for {
+	engine := model.NewEngine(board)
+	startErr := engine.Start()
+	if startErr != nil {
 		/*
-		Check for win condition.
+		Attempt to recover from invalid state.
 		===============================================================
 		*/
-		gameOver, winErr := board.GameWinner()
-		if winErr != nil {
-			/*
-			Attempt to recover from invalid state.
-			=======================================================
-			*/
-			resetWinErr := board.SetWinCondition()
-			if resetWinErr != nil {
-				panic(winErr)
-			}
+		resetWinErr := board.SetWinCondition()
+		if resetWinErr != nil {
+			panic(startErr)
+		}
+		startErr = engine.Start()
+		if startErr != nil {
+			panic(startErr)
 		}
-		if gameOver {
-			/*
-			Game over, display winner and terminate the program.
-			=======================================================
-			*/
-			winner, falseWin := board.WhoWonGame()
-			if falseWin != nil {
-				panic(falseWin)
+	}
+	for engine.Phase() != model.PHASE_GAME_OVER {
+		/*
+		Start a new round.
+		===============================================================
+		*/
+		board.Replicate()
+		board.DisplayScoreBoard()
+		roundErr := playRound(engine)
+		if roundErr != nil && roundErr != model.ErrGameEnded {
+			panic(roundErr)
+		}
+	}
+
+	/*
+	Game over, the winner has been announced so terminate the program.
+	=======================================================================
+	*/
+	board.CloseConnections()
+	os.Exit(0)
+}
+
+/*
+Adds an online player, who answers the prompts over network, to the board.
+
+Returns an error if the name is already taken.
+*/
+func seatOnlinePlayer(board *model.Board, network *model.Network, playerName string) error {
+	player := model.NewPlayer(playerName, false, false, 7)
+	player.SetController(model.NewNetworkController(network, playerName))
+	return board.AddPlayer(*player)
+}
+
+/*
+Follows the game on the host terminal through the events of the board, 
+subscribed before any player is added so that every join is shown. Once 
+the game is won the statistics of the game are shown as well.
+*/
+func watchGame(board *model.Board, hostName string) {
+	stats := model.NewGameStats()
+	board.Subscribe(stats.Record)
+	board.Subscribe(func(event model.GameEvent) {
+		switch event.Type {
+		case model.GAME_PLAYER_JOINED:
+			view.Announce(event.PlayerName + " joined the game")
+		case model.GAME_SUBMISSIONS_REVEALED:
+			// the judge sees the submissions when judging
+			if event.Judge != hostName {
+				view.DisplaySubmissions(event.GreenApple, event.Cards)
 			}
-			view.Winner(winner.PlayerName())
-			board.GameOver(winner.PlayerName())
-			board.CloseConnections()
-			os.Exit(0)
-		} else {
-			/*
-			Start a new round.
-			=======================================================
-			*/
-			board.DisplayScoreBoard()
-			roundErr := playRound(terminal, board)
-			if roundErr != nil {
-				panic(roundErr)
+		case model.GAME_ROUND_WON:
+			view.Announce(event.PlayerName + " won the round with " + event.Card)
+		case model.GAME_PLAYER_DISCONNECTED:
+			view.Announce(event.PlayerName + " lost the connection, their seat is kept until they reconnect")
+		case model.GAME_WON:
+			view.Winner(event.PlayerName)
+			for _, line := range stats.Summary() {
+				view.Announce(line)
 			}
 		}
+	})
+}
+
+/*
+Lets the host player chat and moderate the game from the terminal, and 
+remote admins with the admin password, if one is set.
+*/
+func hostControls(board *model.Board, network *model.Network, playerName string, adminPassword string) {
+	view.SetChatHandler(func(text string) {
+		chatErr := network.Chat(playerName, text)
+		if chatErr != nil {
+			view.Announce(chatErr.Error())
+		}
+	})
+	view.SetCommandHandler(func(command string) {
+		moderateErr := board.Moderate(command)
+		if moderateErr != nil {
+			view.Announce(moderateErr.Error())
+		}
+	})
+	network.SetAdmin(adminPassword, board.Moderate)
+	view.Announce("Type " + view.COMMAND_PREFIX + " and one of " + model.MODERATION_COMMANDS + " to moderate the game.")
+}
+
+/*
+Takes over hosting once the host went away and this client is the backup. 
+The board is restored as of the start of the last round the host 
+replicated, and the other players reconnect with their resume tokens.
+
+Returns an error if the game could not be taken over, otherwise the game 
+is played to the end.
+*/
+func takeOverGame(n *model.Network) error {
+	ctx := context.Background()
+	network, snapshot, promoteErr := n.Promote(ctx)
+	if promoteErr != nil {
+		return promoteErr
+	}
+	board, restoreErr := model.RestoreBoard(snapshot, n.PlayerName())
+	if restoreErr == nil {
+		restoreErr = loadDecks(board)
+	}
+	if restoreErr == nil {
+		restoreErr = board.DealRestored()
+	}
+	if restoreErr != nil {
+		network.CloseConnections()
+		return restoreErr
 	}
+	board.SetNetwork(network)
+	watchGame(board, n.PlayerName())
+	go watchNetwork(ctx, network)
+	view.Announce("The host is gone, you are hosting the game now. The round starts over and the other players rejoin as they reconnect.")
+	hostControls(board, network, n.PlayerName(), "")
+	playGame(view.Terminal(), board)
+	return nil
 }
 
-func playRound(terminal bufio.Scanner, board *model.Board) error {
+func playRound(engine *model.Engine) error {
 	/*
 	Draw a green apple and put it on the board.
 	=======================================================================
 	*/
-	drawGreenErr := board.DrawGreenApple()
-	if drawGreenErr != nil {
-		fmt.Println("could not draw green apple ", drawGreenErr)
-		return drawGreenErr
+	dealErr := engine.Deal()
+	if dealErr == model.ErrGameEnded {
+		return dealErr
+	}
+	if dealErr != nil {
+		fmt.Println("could not draw green apple ", dealErr)
+		return dealErr
 	}
 
 	/*
 	Prompt all players, except the judge, to play a red apple.
 	=======================================================================
 	*/
-	playErr := board.ChooseCards()
+	playErr := engine.Submit()
+	if playErr == model.ErrGameEnded {
+		return playErr
+	}
 	if playErr != nil {
 		fmt.Println("something went wrong during the round, ", playErr)
 		return playErr
@@ -362,99 +527,525 @@ func playRound(terminal bufio.Scanner, board *model.Board) error {
 	Prompt judge for decision.
 	=======================================================================
 	*/
-	winningCardIndex, judgeErr := board.Judge()
+	_, judgeErr := engine.Judge()
+	if judgeErr == model.ErrGameEnded {
+		return judgeErr
+	}
 	if judgeErr != nil {
 		fmt.Println("could not recieve judge decision ", judgeErr)
 		return judgeErr
 	}
 
-	winner, indexErr := board.PlayedCards.ShowPlayer(winningCardIndex)
-	if indexErr != nil {
-		fmt.Println("tried to find player using invalid index ", indexErr)
-		return indexErr
+	/*
+	Award the green apple, discard the played cards, draw new cards and 
+	itterate to the next judge.
+	=======================================================================
+	*/
+	scoreErr := engine.Score()
+	if scoreErr != nil {
+		fmt.Println("could not score the round, ", scoreErr)
+		return scoreErr
 	}
-	fmt.Println(winner, "won the round")
+	return nil
+}
 
-	greenApple, pickErr := board.PickUpGreenApple()
-	if pickErr != nil {
-		fmt.Println("did not find the green apple ", pickErr)
-		return pickErr
+/*
+Reports network events on the host terminal while the game is running.
+*/
+func watchNetwork(ctx context.Context, network *model.Network) {
+	events := network.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			switch event.Type {
+			case model.EVENT_PLAYER_RESUMED:
+				view.Announce(event.PlayerName + " reconnected")
+			case model.EVENT_PLAYER_LAGGING:
+				view.Announce(event.PlayerName + " is lagging")
+			case model.EVENT_PLAYER_LOST:
+				view.Announce(event.PlayerName + " stopped answering pings, dropping the connection")
+			case model.EVENT_JOIN_REFUSED:
+				view.Announce(event.PlayerName + " could not join, " + event.Err.Error())
+			case model.EVENT_SPECTATOR_JOINED:
+				view.Announce(event.PlayerName + " is watching, spectators: " + strings.Join(network.ListSpectators(), ", "))
+			case model.EVENT_SPECTATOR_LEFT:
+				view.Announce(event.PlayerName + " stopped watching")
+			case model.EVENT_CHAT:
+				view.Chat(event.PlayerName, time.Now(), event.Text)
+			case model.EVENT_ADMIN_COMMAND:
+				if event.Err != nil {
+					view.Announce("remote admin at " + event.PlayerName + " failed to run " + event.Text + ", " + event.Err.Error())
+				}
+			case model.EVENT_BACKUP_READY:
+				view.Announce(event.PlayerName + " will take over hosting if the host leaves")
+			case model.EVENT_ACCEPT_ERROR:
+				view.Announce("could not accept connection " + event.Err.Error())
+			}
+		}
 	}
-	board.AwardScore(winner, greenApple)
+}
 
-	/*
-	Discard played cards.
-	=======================================================================
-	*/
-	disErr := board.DiscardRound()
-	if disErr != nil {
-		fmt.Println("could not discard the played cards, ", disErr)
-		return disErr
+/*
+Prompt the user for a connection type and return the matching transport.
+*/
+func chooseTransport(terminal bufio.Scanner, config Config) model.Transport {
+	if view.ChooseTransport(terminal, config.Transport) == "tcp" {
+		return model.NewTCPTransport()
 	}
+	path := view.WebSocketPath(terminal, config.Path)
+	return model.NewWebSocketTransport(path)
+}
 
-	/*
-	Players draw new cards.
-	=======================================================================
-	*/
-	drawErr := board.FillHands()
-	if drawErr != nil {
-		fmt.Println("could not draw new cards, ", drawErr)
-		return drawErr
+/*
+Prompt the host whether to encrypt connections, and if so secure the 
+transport with the configured certificate or a self-signed one. The 
+fingerprint players need to pin the certificate is shown.
+*/
+func secureHost(terminal bufio.Scanner, config Config, transport model.Transport) {
+	if !view.ChooseTLS(terminal, config.TLS) {
+		return
 	}
+	fingerprint, tlsErr := hostTLS(config, transport)
+	if tlsErr != nil {
+		fmt.Println("could not set up TLS ", tlsErr)
+		panic(tlsErr)
+	}
+	fmt.Println("Certificate fingerprint:", fingerprint)
+}
 
-	/*
-	Itterate to the next judge.
-	=======================================================================
-	*/
-	board.ItterateJudge()
+/*
+Secures the transport of a host with the certificate from the config, or a 
+generated self-signed certificate if none is configured.
 
-	return nil
+Returns the fingerprint of the certificate, or an error if it could not be 
+loaded or generated.
+*/
+func hostTLS(config Config, transport model.Transport) (string, error) {
+	var cert tls.Certificate
+	var certErr error
+	if config.Cert != "" {
+		cert, certErr = model.LoadCertificate(config.Cert, config.Key)
+	} else {
+		cert, certErr = model.GenerateCertificate(config.Host)
+	}
+	if certErr != nil {
+		return "", certErr
+	}
+	transport.SetTLS(model.ServerTLSConfig(cert))
+	return model.Fingerprint(cert), nil
 }
 
-func joinGame(terminal bufio.Scanner) (model.Network, error) {
+/*
+Prompt the host for who may join the game and set the password, or 
+generate and show an invite code.
+*/
+func protectGame(terminal bufio.Scanner, config Config, network *model.Network) {
+	access := "open"
+	if config.Invite {
+		access = "invite"
+	} else if config.Password != "" {
+		access = "password"
+	}
+	switch view.ChooseAccess(terminal, access) {
+	case "password":
+		network.SetPassword(view.GamePassword(terminal, config.Password))
+	case "invite":
+		code, codeErr := network.GenerateInviteCode()
+		if codeErr != nil {
+			fmt.Println("could not generate an invite code ", codeErr)
+			panic(codeErr)
+		}
+		fmt.Println("Invite code:", code)
+	}
+}
+
+/*
+Advertises the game on the local network in the background until the 
+context is done, unless discovery is turned off or the game only listens 
+on loopback, where nobody else could join. Failing to announce does not 
+stop the game, it can still be joined by address.
+*/
+func announceGame(ctx context.Context, network *model.Network, config Config, name string, address net.Addr, report func(error)) {
+	if config.Discovery == 0 {
+		return
+	}
+	host, portText, _ := net.SplitHostPort(address.String())
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return
+	}
+	port, _ := strconv.Atoi(portText)
+	go func() {
+		announceErr := network.Announce(ctx, model.BroadcastAddress(config.Discovery), name, port, report)
+		if announceErr != nil {
+			report(announceErr)
+		}
+	}()
+}
+
+/*
+Looks for games on the local network and prompts the player to pick one, 
+unless discovery is turned off.
+
+Returns the game and true if one was picked.
+*/
+func findGame(terminal bufio.Scanner, config Config) (model.DiscoveredGame, bool) {
+	if config.Discovery == 0 {
+		return model.DiscoveredGame{}, false
+	}
+	fmt.Println("Looking for games on the local network...")
+	games, discoverErr := model.DiscoverGames(net.JoinHostPort("", strconv.Itoa(config.Discovery)), model.DISCOVERY_WINDOW)
+	if discoverErr != nil {
+		fmt.Println("could not look for games on the local network ", discoverErr)
+		return model.DiscoveredGame{}, false
+	}
+	if len(games) == 0 {
+		fmt.Println("No games found on the local network.")
+		return model.DiscoveredGame{}, false
+	}
+	descriptions := make([]string, len(games))
+	for i := 0; i < len(games); i++ {
+		descriptions[i] = describeGame(games[i])
+	}
+	index := view.ChooseGame(terminal, descriptions)
+	if index < 0 {
+		return model.DiscoveredGame{}, false
+	}
+	return games[index], true
+}
+
+/*
+Returns a one line description of a game found on the local network.
+*/
+func describeGame(game model.DiscoveredGame) string {
+	description := game.Name + " at " + game.Address + " over " + game.Transport
+	if game.TLS {
+		description += " with tls"
+	}
+	description += ", " + strconv.Itoa(game.Players) + " players"
+	if game.Started {
+		description += ", started, join to watch"
+	} else {
+		description += ", " + strconv.Itoa(game.Seats) + " seats open"
+	}
+	if game.Password {
+		description += ", password"
+	}
+	return description
+}
+
+/*
+Returns the transport a game found on the local network is joined over.
+*/
+func gameTransport(game model.DiscoveredGame) model.Transport {
+	if game.Transport == "tcp" {
+		return model.NewTCPTransport()
+	}
+	return model.NewWebSocketTransport(game.Path)
+}
+
+/*
+Prompt the joining player whether the host uses TLS, and if so for the 
+certificate fingerprint to pin.
+*/
+func secureJoin(terminal bufio.Scanner, config Config, transport model.Transport) {
+	if !view.ChooseTLS(terminal, config.TLS) {
+		return
+	}
+	pin := view.CertificatePin(terminal, config.Pin)
+	transport.SetTLS(model.ClientTLSConfig(pin))
+}
+
+/*
+Connects to the host and joins under a name of the players choosing, asking 
+for a new name until the host accepts it.
+*/
+func joinGame(terminal bufio.Scanner, config Config) (*model.Network, error) {
 	network := new(model.Network)
-	connErr := network.DialHost()
+	var transport model.Transport
+	address := ""
+	game, found := findGame(terminal, config)
+	if found {
+		transport = gameTransport(game)
+		if game.TLS {
+			transport.SetTLS(model.ClientTLSConfig(view.CertificatePin(terminal, config.Pin)))
+		}
+		address = game.Address
+	} else {
+		transport = chooseTransport(terminal, config)
+		secureJoin(terminal, config, transport)
+	}
+	network.SetTransport(transport)
+	network.SetPassword(config.Password)
+	if address == "" {
+		address = view.JoinAddress(terminal, config.Join)
+	}
+	connErr := network.DialHost(address)
 	if connErr != nil {
-		return *network, connErr
+		return network, connErr
+	}
+	spectate := view.JoinAs(terminal)
+	playerName := ""
+	for {
+		if playerName == "" {
+			var namErr error
+			playerName, namErr = view.ChooseName(terminal)
+			if namErr != nil {
+				os.Exit(0)
+			}
+		}
+		join := network.Join
+		if spectate {
+			join = network.Spectate
+		}
+		accepted, reason, joinErr := join(playerName)
+		if joinErr != nil {
+			return network, joinErr
+		}
+		if reason == model.ErrWrongPassword.Error() {
+			network.SetPassword(view.JoinPassword(terminal, reason))
+			continue
+		}
+		if reason == model.ErrTooManyAttempts.Error() {
+			return network, errors.New("the host refused the join, " + reason)
+		}
+		if accepted && spectate {
+			view.Announce("Watching the game as " + playerName + "...")
+			return network, nil
+		}
+		if accepted && network.HostSupports(model.FEATURE_CHAT) {
+			view.Announce("Joined the game as " + playerName + ", waiting for the game to start. Type " + view.CHAT_PREFIX + "and a message to chat.")
+			return network, nil
+		}
+		if accepted {
+			view.Announce("Joined the game as " + playerName + ", waiting for the game to start.")
+			return network, nil
+		}
+		view.Announce("The host rejected the name: " + reason)
+		playerName = ""
+	}
+}
+
+/*
+Returns the cards of a round to choose from, the hand or the submissions.
+*/
+func roundCards(round model.RoundPayload) []model.CardPayload {
+	if round.Action == model.ACTION_JUDGE {
+		return round.Submissions
 	}
-	return *network, nil
+	return round.Hand
 }
 
-func playOnlineGame(n *model.Network) error {
-	host := n.Listen()
-	buffer := make([]byte, 4096)
+/*
+Renders a round received from the host as a heading and the cards to 
+choose from.
+*/
+func describeRound(round model.RoundPayload) ([]string, []string) {
+	var scores []string
+	for _, score := range round.Scores {
+		scores = append(scores, score.Name + " " + strconv.Itoa(score.Score))
+	}
+	heading := []string{
+		"Round " + strconv.Itoa(round.Round) + ", judged by " + round.Judge,
+		"Scores: " + strings.Join(scores, ", "),
+		"Current green apple - " + round.GreenApple.Header + " - " + round.GreenApple.Description,
+	}
+	if round.Action == model.ACTION_JUDGE {
+		heading = append(heading, "Select the winning card:")
+	} else {
+		heading = append(heading, "Please select a card to play:")
+	}
+	var cards []string
+	for _, card := range roundCards(round) {
+		cards = append(cards, card.Header + " - " + card.Description)
+	}
+	return heading, cards
+}
 
+/*
+Reads messages from the host in the background, so that prompts can be 
+cancelled by the host while waiting for terminal input.
+*/
+func receiveMessages(n *model.Network, messages chan<- model.Message, failure chan<- error) {
 	for {
-		_, err := host.Read(buffer)
+		msg, err := n.Receive()
 		if err != nil {
-			return err
+			failure <- err
+			return
 		}
+		messages <- msg
+	}
+}
+
+/*
+Plays an online game until it is over. Whenever they are not prompted 
+players can type ready to vote to start the game on a dedicated server, and 
+players in a lobby room can type leave to go back to the lobby.
 
-		receivedData := string(buffer)
-		parsed := strings.SplitAfterN(receivedData, "\n", -1)
-		for i := 0; i < len(parsed); i++ {
-			parsed[i] = strings.Trim(parsed[i], "\n")
+Returns nil when the game is over or the player left, or an error if the 
+connection to the host could not be restored.
+*/
+func playOnlineGame(n *model.Network, inRoom bool) error {
+	messages := make(chan model.Message)
+	failure := make(chan error, 1)
+	go receiveMessages(n, messages, failure)
+	view.SetChatHandler(func(text string) {
+		n.SendChat(text)
+	})
+	defer view.SetChatHandler(nil)
+
+	var promptID int
+	cancelPrompt := func() {}
+	defer func() { cancelPrompt() }()
+
+	commands := []string{"ready"}
+	if inRoom {
+		commands = append(commands, "leave")
+	}
+	answered := make(chan int, 1)
+	typed := make(chan string, 1)
+	cancelIdle := func() {}
+	defer func() { cancelIdle() }()
+	waitForCommand := func() {
+		cancelIdle()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelIdle = cancel
+		go func() {
+			command, err := view.WaitForCommand(ctx, commands)
+			if err == nil {
+				typed <- command
+			}
+		}()
+	}
+	waitForCommand()
+
+	for {
+		var msg model.Message
+		select {
+		case command := <-typed:
+			if command == "leave" {
+				cancelPrompt()
+				view.Announce("Leaving the room...")
+				return n.Leave()
+			}
+			n.Ready()
+			view.Announce("You voted to start the game.")
+			waitForCommand()
+			continue
+		case id := <-answered:
+			if id == promptID {
+				waitForCommand()
+			}
+			continue
+		case err := <-failure:
+			cancelPrompt()
+			view.Announce("Lost the connection to the host (" + err.Error() + "), reconnecting...")
+			if n.IsBackup() {
+				// the backup gives the host a moment to come back before taking over
+				reconnectErr := n.Reconnect(MIGRATION_ATTEMPTS, RECONNECT_DELAY)
+				if reconnectErr != nil {
+					cancelIdle()
+					view.SetChatHandler(nil)
+					return takeOverGame(n)
+				}
+				view.Announce("Reconnected to the game.")
+				go receiveMessages(n, messages, failure)
+				continue
+			}
+			reconnectErr := n.Reconnect(RECONNECT_ATTEMPTS, RECONNECT_DELAY)
+			if reconnectErr != nil {
+				return reconnectErr
+			}
+			view.Announce("Reconnected to the game.")
+			go receiveMessages(n, messages, failure)
+			continue
+		case msg = <-messages:
 		}
 
-		if parsed[0] == "Play" {
-			validInput, err := strconv.ParseInt(parsed[1], 10, 64)
-			if err != nil {
-				panic(errors.New("Received invalid RPC format " + parsed[1]))
+		switch msg.Type {
+		case model.MSG_PLAY:
+			var play model.PlayPayload
+			decodeErr := msg.Decode(&play)
+			if decodeErr != nil {
+				return errors.New("received invalid play message, " + decodeErr.Error())
 			}
-			input := view.OnlinePlay(int(validInput), parsed[2:])
-			host.Write([]byte(input))
-
-		} else if parsed[0] == "Display" {
-			view.OnlineDisplay(parsed[1:])
-			
-		} else if parsed[0] == "End" {
-			fmt.Println("Game over,", parsed[1], "won!")
-			os.Exit(0)
-		} else {
-			fmt.Println("unknown RPC")
-			for i := 0; i < len(parsed); i++ {
-				fmt.Println("--"+parsed[i]+"--")
+			cancelPrompt()
+			cancelIdle()
+			ctx, cancel := context.WithCancel(context.Background())
+			promptID, cancelPrompt = msg.ID, cancel
+			go func(id int) {
+				if play.Round != nil {
+					heading, cards := describeRound(*play.Round)
+					choice, err := view.OnlineCards(ctx, heading, cards, play.Error)
+					if err != nil {
+						return
+					}
+					n.RespondCard(id, roundCards(*play.Round)[choice].ID)
+				} else {
+					input, err := view.OnlinePlay(ctx, play.ValidOptions, play.Lines, play.Error)
+					if err != nil {
+						return
+					}
+					n.Respond(id, input)
+				}
+				select {
+				case answered <- id:
+				default:
+				}
+			}(msg.ID)
+
+		case model.MSG_PING:
+			n.Pong(msg.ID)
+
+		case model.MSG_SNAPSHOT:
+			backupErr := n.StoreSnapshot(msg)
+			if backupErr != nil {
+				fmt.Println("could not prepare to take over hosting ", backupErr)
+			}
+
+		case model.MSG_BACKUP:
+			n.StoreBackup(msg)
+
+		case model.MSG_CHAT:
+			var chat model.ChatPayload
+			decodeErr := msg.Decode(&chat)
+			if decodeErr != nil {
+				return errors.New("received invalid chat message, " + decodeErr.Error())
+			}
+			view.Chat(chat.From, chat.Time, chat.Text)
+
+		case model.MSG_CANCEL:
+			if msg.ID == promptID {
+				cancelPrompt()
+				fmt.Println("Time is up.")
+				waitForCommand()
+			}
+
+		case model.MSG_DISPLAY:
+			var display model.DisplayPayload
+			decodeErr := msg.Decode(&display)
+			if decodeErr != nil {
+				return errors.New("received invalid display message, " + decodeErr.Error())
+			}
+			view.OnlineDisplay(display.Lines)
+
+		case model.MSG_END:
+			var end model.EndPayload
+			decodeErr := msg.Decode(&end)
+			if decodeErr != nil {
+				return errors.New("received invalid end message, " + decodeErr.Error())
 			}
+			if end.Reason != "" {
+				fmt.Println(end.Reason)
+			}
+			if end.Winner != "" {
+				fmt.Println("Game over,", end.Winner, "won!")
+			}
+			return nil
+
+		default:
+			fmt.Println("unknown message type", msg.Type)
 		}
 	}
 }
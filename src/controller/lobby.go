@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"main/model"
+	"main/view"
+	"os"
+	"time"
+)
+
+/*
+Runs a lobby server that hosts a game in every room the clients create,
+until the process is stopped. Everything is taken from the config and
+logged, nothing is prompted.
+*/
+func Lobby(config Config) {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	lobby := new(model.Lobby)
+	lobby.SetLogger(logger)
+	lobby.SetHeartbeat(time.Duration(config.Heartbeat) * time.Second, config.MaxMissed)
+	lobby.SetRunner(func(ctx context.Context, room *model.Room) {
+		runRoom(ctx, room, config)
+	})
+
+	transport := configTransport(config)
+	if config.TLS {
+		fingerprint, tlsErr := hostTLS(config, transport)
+		if tlsErr != nil {
+			logger.Fatalln("could not set up TLS", tlsErr)
+		}
+		logger.Println("Certificate fingerprint", fingerprint)
+	}
+	listen, listenErr := transport.Listen(config.ListenAddress())
+	if listenErr != nil {
+		logger.Fatalln("could not start the lobby", listenErr)
+	}
+	logger.Println("Lobby listening on", listen.Addr().String(), "over", transport.Name())
+	serveErr := lobby.Serve(context.Background(), listen)
+	if serveErr != nil {
+		logger.Fatalln("lobby stopped", serveErr)
+	}
+}
+
+/*
+Returns the transport set in the config, without prompting.
+*/
+func configTransport(config Config) model.Transport {
+	if config.Transport == "tcp" {
+		return model.NewTCPTransport()
+	}
+	return model.NewWebSocketTransport(config.Path)
+}
+
+/*
+Runs the game of a lobby room. The room waits until it is full, fills the
+remaining seats with bots and plays until the game is over. The room is
+closed if everyone leaves before the game starts.
+*/
+func runRoom(ctx context.Context, room *model.Room, config Config) {
+	logger := log.New(os.Stdout, "[" + room.Name() + "] ", log.LstdFlags)
+	network := room.Network()
+
+	/*
+	Wait for the room to fill up.
+	=======================================================================
+	*/
+	events := network.Events()
+	for network.CountOnlinePlayers() < room.Capacity() {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			switch event.Type {
+			case model.EVENT_PLAYER_JOINED:
+				logger.Println(event.PlayerName, "joined,", network.CountOnlinePlayers(), "of", room.Capacity(), "players")
+			case model.EVENT_PLAYER_LEFT:
+				logger.Println(event.PlayerName, "left,", network.CountOnlinePlayers(), "of", room.Capacity(), "players")
+				if network.CountOnlinePlayers() == 0 {
+					return
+				}
+			case model.EVENT_SPECTATOR_JOINED:
+				logger.Println(event.PlayerName, "is watching,", network.CountSpectators(), "spectators")
+			}
+		}
+	}
+	network.StartGame()
+	network.MassDisplay("The room is full, the game is starting!")
+	go logNetwork(ctx, network, logger)
+	runHeadlessGame(network, config, logger)
+}
+
+/*
+Connects to a lobby server and lets the player create or enter rooms, going
+back to the room list after every game until the player quits.
+
+Returns an error if the lobby can not be reached.
+*/
+func joinLobby(terminal bufio.Scanner, config Config) error {
+	transport := chooseTransport(terminal, config)
+	secureJoin(terminal, config, transport)
+	address := view.JoinAddress(terminal, config.Join)
+	playerName := ""
+	for {
+		network := new(model.Network)
+		network.SetTransport(transport)
+		connErr := network.DialHost(address)
+		if connErr != nil {
+			return connErr
+		}
+		entered, spectate, enterErr := chooseRoom(network)
+		if enterErr != nil {
+			return enterErr
+		}
+		if !entered {
+			os.Exit(0)
+		}
+		joined, joinErr := joinRoom(network, &playerName, spectate)
+		if joinErr != nil {
+			view.Announce("Lost the connection to the room, " + joinErr.Error())
+			continue
+		}
+		if !joined {
+			continue
+		}
+		playErr := playOnlineGame(network, true)
+		if playErr != nil {
+			view.Announce("Lost the connection to the room, " + playErr.Error())
+		}
+	}
+}
+
+/*
+Shows the rooms of the lobby until the player enters, watches or creates 
+one. Returns true as the second value if the player is going to watch.
+
+Returns false if the player chose to quit, or an error if the connection
+to the lobby fails.
+*/
+func chooseRoom(network *model.Network) (bool, bool, error) {
+	for {
+		rooms, listErr := network.ListRooms()
+		if listErr != nil {
+			return false, false, listErr
+		}
+		roomLines := make([]string, len(rooms))
+		for i := 0; i < len(rooms); i++ {
+			roomLines[i] = rooms[i].Name + " (" + fmt.Sprint(rooms[i].Players) + "/" + fmt.Sprint(rooms[i].Capacity) + " players)"
+			if rooms[i].Started {
+				roomLines[i] += " playing"
+			}
+		}
+
+		var result model.RoomResultPayload
+		var roomErr error
+		action, index := view.LobbyMenu(roomLines)
+		switch action {
+		case "quit":
+			return false, false, nil
+		case "refresh":
+			continue
+		case "create":
+			name, players := view.RoomSettings(model.MAX_ROOM_PLAYERS)
+			result, roomErr = network.CreateRoom(name, players)
+		case "enter", "watch":
+			result, roomErr = network.EnterRoom(rooms[index].Name)
+		}
+		if roomErr != nil {
+			return false, false, roomErr
+		}
+		if result.Accepted {
+			view.Announce("Entered the room " + result.Name)
+			return true, action == "watch", nil
+		}
+		view.Announce("Could not enter the room: " + result.Reason)
+	}
+}
+
+/*
+Joins the room under the players name, as a player or a spectator, asking 
+for a new name until the room accepts it. The accepted name is kept for the 
+next room.
+
+Returns false if the room can no longer be joined, or an error if the
+connection fails.
+*/
+func joinRoom(network *model.Network, playerName *string, spectate bool) (bool, error) {
+	rejection := ""
+	join := network.Join
+	if spectate {
+		join = network.Spectate
+	}
+	for {
+		if *playerName == "" || rejection != "" {
+			*playerName = view.LobbyName(rejection)
+		}
+		accepted, reason, joinErr := join(*playerName)
+		if joinErr != nil {
+			return false, joinErr
+		}
+		if accepted && spectate {
+			view.Announce("Watching the room as " + *playerName + ". Type leave to go back to the lobby.")
+			return true, nil
+		}
+		if accepted {
+			view.Announce("Joined the room as " + *playerName + ", waiting for the room to fill up. Type leave to go back to the lobby.")
+			return true, nil
+		}
+		if reason == model.ErrGameStarted.Error() || reason == model.ErrGameFull.Error() {
+			view.Announce("Could not join the room: " + reason)
+			return false, nil
+		}
+		rejection = reason
+	}
+}
+
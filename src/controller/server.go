@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"main/model"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Runs a dedicated server for a single game, without a host player. The game 
+starts once every joined player voted ready, or as soon as the first player 
+joins if the config says so. Everything is taken from the config and 
+logged, nothing is prompted.
+*/
+func Server(config Config) {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	network := new(model.Network)
+	transport := configTransport(config)
+	if config.TLS {
+		fingerprint, tlsErr := hostTLS(config, transport)
+		if tlsErr != nil {
+			logger.Fatalln("could not set up TLS", tlsErr)
+		}
+		logger.Println("Certificate fingerprint", fingerprint)
+	}
+	network.SetTransport(transport)
+	network.SetHeartbeat(time.Duration(config.Heartbeat) * time.Second, config.MaxMissed)
+	network.SetCapacity(model.MAX_ROOM_PLAYERS)
+	if config.Invite {
+		code, codeErr := network.GenerateInviteCode()
+		if codeErr != nil {
+			logger.Fatalln("could not generate an invite code", codeErr)
+		}
+		logger.Println("Invite code", code)
+	} else {
+		network.SetPassword(config.Password)
+	}
+
+	ctx, stopListener := context.WithCancel(context.Background())
+	defer stopListener()
+	address, listenErr := network.Listener(ctx, config.ListenAddress())
+	if listenErr != nil {
+		logger.Fatalln("could not start the server", listenErr)
+	}
+	logger.Println("Server listening on", address.String(), "over", network.Transport().Name())
+	announceGame(ctx, network, config, "Dedicated server", address, func(err error) {
+		logger.Println("could not announce the game on the local network", err)
+	})
+
+	waitForStart(network, config.Start, logger)
+	network.StartGame()
+	network.MassDisplay("The game is starting!")
+	go logNetwork(ctx, network, logger)
+	runHeadlessGame(network, config, logger)
+	network.CloseConnections()
+}
+
+/*
+Waits until the game may start. With the start policy "first" that is as 
+soon as a player joins, with "vote" once every joined player voted ready.
+*/
+func waitForStart(network *model.Network, start string, logger *log.Logger) {
+	events := network.Events()
+	for {
+		event := <-events
+		switch event.Type {
+		case model.EVENT_PLAYER_JOINED:
+			logger.Println(event.PlayerName, "joined,", network.CountOnlinePlayers(), "players connected")
+			if start == START_FIRST {
+				return
+			}
+			network.Display(event.PlayerName, "Type ready when you want to start, the game starts once everyone is ready.")
+		case model.EVENT_PLAYER_LEFT:
+			logger.Println(event.PlayerName, "left,", network.CountOnlinePlayers(), "players connected")
+		case model.EVENT_SPECTATOR_JOINED:
+			logger.Println(event.PlayerName, "is watching,", network.CountSpectators(), "spectators")
+		case model.EVENT_CHAT:
+			logger.Println(event.PlayerName + ":", event.Text)
+		case model.EVENT_PLAYER_READY:
+			logger.Println(event.PlayerName, "is ready,", network.CountReady(), "of", network.CountOnlinePlayers(), "players ready")
+			network.MassDisplay(event.PlayerName + " is ready to start")
+		case model.EVENT_JOIN_REFUSED:
+			logger.Println(event.PlayerName, "could not join,", event.Err)
+		case model.EVENT_ACCEPT_ERROR:
+			logger.Println("could not accept connection", event.Err)
+		}
+		// a player leaving can make everyone who is left ready
+		if start == START_VOTE && network.AllReady() {
+			return
+		}
+	}
+}
+
+/*
+Logs network events while the game of a dedicated server is running.
+*/
+func logNetwork(ctx context.Context, network *model.Network, logger *log.Logger) {
+	events := network.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			switch event.Type {
+			case model.EVENT_PLAYER_LEFT:
+				logger.Println(event.PlayerName, "lost the connection")
+			case model.EVENT_PLAYER_RESUMED:
+				logger.Println(event.PlayerName, "reconnected")
+			case model.EVENT_PLAYER_LAGGING:
+				logger.Println(event.PlayerName, "is lagging")
+			case model.EVENT_PLAYER_LOST:
+				logger.Println(event.PlayerName, "stopped answering pings")
+			case model.EVENT_JOIN_REFUSED:
+				logger.Println(event.PlayerName, "could not join,", event.Err)
+			case model.EVENT_SPECTATOR_JOINED:
+				logger.Println(event.PlayerName, "is watching,", network.CountSpectators(), "spectators")
+			case model.EVENT_SPECTATOR_LEFT:
+				logger.Println(event.PlayerName, "stopped watching,", network.CountSpectators(), "spectators")
+			case model.EVENT_CHAT:
+				logger.Println(event.PlayerName + ":", event.Text)
+			case model.EVENT_ADMIN_COMMAND:
+				logger.Println("remote admin at", event.PlayerName, "ran", event.Text, "error:", event.Err)
+			case model.EVENT_BACKUP_READY:
+				logger.Println(event.PlayerName, "is the backup host at", event.Text)
+			case model.EVENT_ACCEPT_ERROR:
+				logger.Println("could not accept connection", event.Err)
+			}
+		}
+	}
+}
+
+/*
+Plays a game without a host player on a network that has started, all 
+seats are online players or bots and progress is written to the logger 
+instead of the terminal. Remote admins can moderate the game with the 
+admin password from the config. Returns once the game is over or fails.
+*/
+func runHeadlessGame(network *model.Network, config Config, logger *log.Logger) {
+	logger.Println("game started")
+
+	/*
+	Create players and add them to the board.
+	=======================================================================
+	*/
+	board := new(model.Board)
+	stats := model.NewGameStats()
+	board.Subscribe(stats.Record)
+	onlinePlayerNames := network.ListPlayers()
+	for i := 0; i < len(onlinePlayerNames); i++ {
+		seatOnlinePlayer(board, network, onlinePlayerNames[i])
+	}
+	for i := 0; board.CountPlayers() < 4; i++ {
+		// a player may already have taken the name of a bot
+		board.AddPlayer(*model.NewPlayer("Bot" + fmt.Sprint(i), false, true, 7))
+	}
+	board.SetNetwork(network)
+	board.SetLogger(logger)
+	board.SetTimers(time.Duration(config.TurnSeconds) * time.Second, time.Duration(config.JudgeSeconds) * time.Second)
+	board.SetBotCover(config.BotCover)
+	network.SetAdmin(config.AdminPassword, board.Moderate)
+
+	prepareErr := prepareBoard(board)
+	if prepareErr != nil {
+		logger.Println("could not set up the game", prepareErr)
+		return
+	}
+
+	/*
+	Play until there is a winner.
+	=======================================================================
+	*/
+	engine := model.NewEngine(board)
+	engine.Subscribe(func(event model.GameEvent) {
+		switch event.Type {
+		case model.GAME_ROUND_STARTED:
+			logger.Println("round", event.Round, "started, the judge is", event.Judge)
+		case model.GAME_ROUND_WON:
+			logger.Println(event.PlayerName, "won the round")
+		case model.GAME_WON:
+			logger.Println(event.PlayerName, "won the game")
+		}
+	})
+	startErr := engine.Start()
+	if startErr != nil {
+		logger.Println("could not start the game", startErr)
+		return
+	}
+	for engine.Phase() != model.PHASE_GAME_OVER {
+		board.Replicate()
+		board.DisplayScoreBoard()
+		roundErr := playRound(engine)
+		if roundErr != nil && roundErr != model.ErrGameEnded {
+			logger.Println("the round failed", roundErr)
+			return
+		}
+	}
+	if stats.Winner() == "" {
+		logger.Println("could not find the winner")
+	}
+	for _, line := range stats.Summary() {
+		logger.Println(line)
+	}
+}
+
+/*
+Loads and shuffles the decks, deals the starting hands and picks the first
+judge and the win condition.
+
+Returns the first error encountered.
+*/
+func prepareBoard(board *model.Board) error {
+	loadErr := loadDecks(board)
+	if loadErr != nil {
+		return loadErr
+	}
+
+	/*
+	Shuffle the decks and the player order.
+	=======================================================================
+	*/
+	shuffleGreenErr := board.ShuffleGreenApples()
+	if shuffleGreenErr != nil {
+		return shuffleGreenErr
+	}
+	shuffleRedErr := board.ShuffleRedApples()
+	if shuffleRedErr != nil {
+		return shuffleRedErr
+	}
+	shufflePlayerErr := board.ShufflePlayers()
+	if shufflePlayerErr != nil {
+		return shufflePlayerErr
+	}
+
+	/*
+	Deal the starting hands, pick the judge and set the win condition.
+	=======================================================================
+	*/
+	drawCardErr := board.FillHands()
+	if drawCardErr != nil {
+		return drawCardErr
+	}
+	initJudgeErr := board.InitializeJudge()
+	if initJudgeErr != nil {
+		return initJudgeErr
+	}
+	return board.SetWinCondition()
+}
+
+/*
+Loads the card decks and adds them to the board.
+
+Returns the first error encountered.
+*/
+func loadDecks(board *model.Board) error {
+	absRedPath, redPathErr := filepath.Abs("../src/resources/redApples.txt")
+	if redPathErr != nil {
+		return redPathErr
+	}
+	redPathErr = board.LoadRedApples(absRedPath)
+	if redPathErr != nil {
+		return redPathErr
+	}
+	absGreenPath, greenPathErr := filepath.Abs("../src/resources/greenApples.txt")
+	if greenPathErr != nil {
+		return greenPathErr
+	}
+	return board.LoadGreenApples(absGreenPath)
+}
+
@@ -1,7 +1,28 @@
 package main
 
-import "main/controller"
+import (
+	"fmt"
+	"main/controller"
+	"os"
+)
 
 func main() {
-	controller.Game()
-}
\ No newline at end of file
+	config, configErr := controller.LoadConfig(os.Args[1:])
+	if configErr != nil {
+		fmt.Println(configErr)
+		os.Exit(2)
+	}
+	if config.Command != "" {
+		controller.Admin(config)
+		return
+	}
+	if config.Lobby {
+		controller.Lobby(config)
+		return
+	}
+	if config.Server {
+		controller.Server(config)
+		return
+	}
+	controller.Game(config)
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"net"
+)
+
+/*
+Returned when a join comes from an address the host has banned.
+*/
+var ErrBanned = errors.New("you are banned from this game")
+
+/*
+Returned when an admin command does not present the admin password.
+*/
+var ErrWrongAdminPassword = errors.New("wrong admin password")
+
+/*
+Sets the password remote admins have to send with their commands and the
+function that runs the commands, see Board.Moderate. Remote admin is turned
+off while either is empty.
+*/
+func (n *Network) SetAdmin(password string, handler func(command string) error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.adminPassword = password
+	n.adminHandler = handler
+}
+
+/*
+Runs an admin command received from a connection and answers with the
+result. Failed passwords count towards the same limit as failed joins.
+
+Returns ErrTooManyAttempts if the address is locked out.
+*/
+func (n *Network) handleAdmin(player *PlayerConnection, msg Message) error {
+	var admin AdminPayload
+	decodeErr := msg.Decode(&admin)
+	if decodeErr != nil {
+		player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: "invalid admin message"})
+		return nil
+	}
+	n.lock.Lock()
+	password, handler := n.adminPassword, n.adminHandler
+	n.lock.Unlock()
+	if password == "" || handler == nil {
+		player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: "remote admin is turned off"})
+		return nil
+	}
+	passwordErr := n.checkAttempt(player.conn.RemoteAddr(), password, admin.Password, ErrWrongAdminPassword)
+	if passwordErr != nil {
+		player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: passwordErr.Error()})
+		return passwordErr
+	}
+
+	commandErr := handler(admin.Command)
+	n.emit(NetworkEvent{Type: EVENT_ADMIN_COMMAND, PlayerName: addressIP(player.conn.RemoteAddr()), Text: admin.Command, Err: commandErr})
+	if commandErr != nil {
+		player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: commandErr.Error()})
+		return nil
+	}
+	player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Accepted: true})
+	return nil
+}
+
+/*
+Removes an online player from the game for good, their seat can not be
+resumed. The player is told why before the connection is closed.
+
+Returns an error if there is no online player with that name.
+*/
+func (n *Network) Kick(name string, reason string) error {
+	n.lock.Lock()
+	index := n.indexOf(name)
+	if index < 0 {
+		n.lock.Unlock()
+		return errors.New("no online player named " + name)
+	}
+	player := n.players[index]
+	n.players = append(n.players[:index], n.players[index+1:]...)
+	n.lock.Unlock()
+
+	player.writer.Send(MSG_END, 0, EndPayload{Reason: reason})
+	player.conn.Close()
+	n.emit(NetworkEvent{Type: EVENT_PLAYER_KICKED, PlayerName: name})
+	return nil
+}
+
+/*
+Bans an address for as long as the network runs, given as an IP or as the
+name of a player or spectator connected from it. Spectators from the
+address are disconnected.
+
+Returns the names of the players connected from the address, who still
+have to be kicked, or an error if the target is neither a known name nor
+an IP.
+*/
+func (n *Network) Ban(target string) ([]string, error) {
+	n.lock.Lock()
+	address := target
+	if index := n.indexOf(target); index >= 0 {
+		address = addressIP(n.players[index].conn.RemoteAddr())
+	} else if index := n.spectatorIndex(target); index >= 0 {
+		address = addressIP(n.spectators[index].conn.RemoteAddr())
+	}
+	if net.ParseIP(address) == nil {
+		n.lock.Unlock()
+		return nil, errors.New("no player, spectator or address " + target)
+	}
+	if n.banned == nil {
+		n.banned = make(map[string]bool)
+	}
+	n.banned[address] = true
+
+	var players []string
+	for i := 0; i < len(n.players); i++ {
+		if addressIP(n.players[i].conn.RemoteAddr()) == address {
+			players = append(players, n.players[i].playerName)
+		}
+	}
+	var spectators []*PlayerConnection
+	for i := 0; i < len(n.spectators); i++ {
+		if addressIP(n.spectators[i].conn.RemoteAddr()) == address {
+			spectators = append(spectators, n.spectators[i])
+		}
+	}
+	n.lock.Unlock()
+
+	for i := 0; i < len(spectators); i++ {
+		spectators[i].writer.Send(MSG_END, 0, EndPayload{Reason: ErrBanned.Error()})
+		spectators[i].conn.Close()
+	}
+	return players, nil
+}
+
+/*
+Returns true if the address has been banned.
+*/
+func (n *Network) isBanned(address net.Addr) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.banned[addressIP(address)]
+}
+
+/*
+Sends a moderation command to the host, see Board.Moderate.
+
+Returns whether the host ran the command along with the reason if it did
+not, or an error if the connection fails.
+*/
+func (n *Network) Admin(password string, command string) (bool, string, error) {
+	var result AdminResultPayload
+	err := n.request(MSG_ADMIN, AdminPayload{Password: password, Command: command}, MSG_ADMIN_RESULT, &result)
+	if err != nil {
+		return false, "", err
+	}
+	return result.Accepted, result.Reason, nil
+}
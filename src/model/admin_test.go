@@ -0,0 +1,75 @@
+package model_test
+
+import (
+	"main/model"
+	"testing"
+)
+
+func TestBan(t *testing.T) {
+	network, conn, reader, _ := generateTestNetwork(t)
+	defer conn.Close()
+	if _, banErr := network.Ban("nobody"); banErr == nil {
+		t.Log("expected banning an unknown target to fail")
+		t.FailNow()
+	}
+	players, banErr := network.Ban("online player 0")
+	if banErr != nil || len(players) != 1 || players[0] != "online player 0" {
+		t.Log("expected the player to be banned", players, banErr)
+		t.FailNow()
+	}
+	kickErr := network.Kick("online player 0", "banned")
+	if kickErr != nil {
+		t.Log("unexpected kick error:", kickErr)
+		t.FailNow()
+	}
+	receiveUntil(t, reader, model.MSG_END)
+
+	_, retryReader, retryWriter := dialTestClient(t, conn)
+	result := sendTestJoin(t, retryReader, retryWriter, model.JoinPayload{Name: "again"})
+	if result.Accepted || result.Reason != model.ErrBanned.Error() {
+		t.Log("expected the banned address to be refused", result)
+		t.FailNow()
+	}
+}
+
+func TestRemoteAdmin(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	client := new(model.Network)
+	dialErr := client.DialHost(conn.RemoteAddr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+
+	accepted, _, adminErr := client.Admin("root", "pause")
+	if adminErr != nil || accepted {
+		t.Log("expected remote admin to be turned off", adminErr)
+		t.FailNow()
+	}
+
+	var commands []string
+	network.SetAdmin("root", func(command string) error {
+		commands = append(commands, command)
+		return nil
+	})
+	accepted, reason, adminErr := client.Admin("guess", "pause")
+	if adminErr != nil || accepted || reason != model.ErrWrongAdminPassword.Error() {
+		t.Log("expected the wrong admin password to be refused", reason, adminErr)
+		t.FailNow()
+	}
+	accepted, reason, adminErr = client.Admin("root", "pause")
+	if adminErr != nil || !accepted {
+		t.Log("expected the command to be run", reason, adminErr)
+		t.FailNow()
+	}
+	if len(commands) != 1 || commands[0] != "pause" {
+		t.Log("expected the handler to receive the command", commands)
+		t.FailNow()
+	}
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_ADMIN_COMMAND || event.Text != "pause" {
+		t.Log("expected an admin command event, received", event)
+		t.FailNow()
+	}
+}
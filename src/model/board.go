@@ -1,23 +1,45 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"main/view"
 )
 
 type Board struct {
-	network Network
+	network *Network
 	players []Player
 	judge int
 	currentGreenApple Card
+	round int
 	redApples Deck
 	greenApples Deck
 	PlayedCards PlayedApples
 	winCondition int
+	turnTimeout time.Duration
+	judgeTimeout time.Duration
+	fallbackJudge func(redApples []string) int
+	botCover bool
+	logger *log.Logger
+	lock sync.Mutex
+	paused chan struct{}
+	kicked []string
+	ended bool
+	cancelRound context.CancelFunc
+	cancelJudge context.CancelFunc
+	judgeSkipped bool
+	eventLock sync.Mutex
+	subscribers []subscriber
+	nextSubscriber int
+	stopBroadcast func()
 }
 
 
@@ -29,7 +51,11 @@ Returns an error for name collisions.
 */
 func (b *Board) AddPlayer(player Player) error {
 	if b.validateName(player.PlayerName()) {
+		if player.controller == nil && b.network != nil {
+			player.controller = NewNetworkController(b.network, player.PlayerName())
+		}
 		b.players = append(b.players, player)
+		b.emit(GameEvent{Type: GAME_PLAYER_JOINED, PlayerName: player.PlayerName()})
 		return nil
 	}
 	return errors.New("name is unavailable")
@@ -92,21 +118,43 @@ func (b *Board) AllHandsFull() bool {
 }
 
 /*
-Returns the string representation of players and their score.
+Returns the string representation of players and their score. Online 
+players also show the state of their connection.
 */
 func (b *Board) DisplayScoreBoard() {
 	var playerScores []string
 	var onlineView string
-	playerScores = append(playerScores, "Player name\t\tScore")
-	onlineView = onlineView + "Player name\t\tScore\n"
+	header := "Player name\t\tScore"
+	if b.network != nil {
+		header += "\tConnection"
+	}
+	playerScores = append(playerScores, header)
+	onlineView = onlineView + header + "\n"
 	for i := 0; i < len(b.players); i++ {
 		playerName := b.players[i].PlayerName()
 		playerScore := b.players[i].Score()
 		scoreLine := playerName + ": \t\t\t" + fmt.Sprint(playerScore)
+		if b.network != nil {
+			// only online players have a connection
+			state, stateErr := b.network.ConnectionState(playerName)
+			if stateErr == nil {
+				scoreLine += "\t" + string(state)
+			}
+		}
 		playerScores = append(playerScores, scoreLine)
 		onlineView += scoreLine+"\n"
 	}
-	view.ScoreBoard(playerScores)
+	if b.network != nil && b.network.CountSpectators() > 0 {
+		spectators := b.network.ListSpectators()
+		playerScores = append(playerScores, "Spectators: " + strings.Join(spectators, ", "))
+		onlineView += "Spectators watching: " + strconv.Itoa(len(spectators)) + "\n"
+	}
+	if b.logger != nil {
+		b.logger.Println(strings.Join(playerScores, "\n"))
+	} else {
+		view.ScoreBoard(playerScores)
+	}
+	b.MassDisplay(strings.TrimSuffix(onlineView, "\n"))
 }
 
 /*
@@ -165,6 +213,7 @@ func (b *Board) ItterateJudge() {
 	if b.judge >= len(b.players) {
 		b.judge = 0
 	}
+	b.emit(GameEvent{Type: GAME_JUDGE_ROTATED, Round: b.round, Judge: b.CurrentJudgeName()})
 }
 
 /*
@@ -218,7 +267,7 @@ func (b *Board) ShuffleGreenApples() error {
 }
 
 /*
-Draws a green apple and places it on the board.
+Draws a green apple and places it on the board, starting the next round.
 
 Returns an error if a green apple can not be drawn.
 */
@@ -228,9 +277,17 @@ func (b *Board) DrawGreenApple() error {
 		return err
 	}
 	b.currentGreenApple = card
+	b.round++
 	return nil
 }
 
+/*
+Returns the number of the current round, counting from 1.
+*/
+func (b *Board) Round() int {
+	return b.round
+}
+
 /*
 Returns the string representation of the current green apple on the board.
 */
@@ -311,95 +368,275 @@ func (b *Board) WhoWonGame() (Player, error) {
 }
 
 /*
-Sets the network component.
+Sets the network component, offline games have none. The spectators of the 
+network follow the game through its events.
 */
-func (b *Board) SetNetwork(network Network) {
+func (b *Board) SetNetwork(network *Network) {
 	b.network = network
+	for i := 0; i < len(b.players); i++ {
+		if b.players[i].controller == nil && network != nil {
+			b.players[i].controller = NewNetworkController(network, b.players[i].PlayerName())
+		}
+	}
+	if b.stopBroadcast != nil {
+		b.stopBroadcast()
+		b.stopBroadcast = nil
+	}
+	if network != nil {
+		network.Observe(b.networkEvent)
+		b.stopBroadcast = b.Subscribe(b.broadcast)
+	}
+}
+
+/*
+Returns the network component, or an error for offline games.
+*/
+func (b *Board) onlineNetwork() (*Network, error) {
+	if b.network == nil {
+		return nil, errors.New("board has no network")
+	}
+	return b.network, nil
+}
+
+/*
+Sets how long players have to submit a card and how long the judge has to 
+pick a winner each round. A timer of zero waits forever.
+*/
+func (b *Board) SetTimers(turn time.Duration, judge time.Duration) {
+	b.turnTimeout = turn
+	b.judgeTimeout = judge
+}
+
+/*
+Sets the strategy that picks the winner when the judge runs out of time.
+*/
+func (b *Board) SetFallbackJudge(strategy func(redApples []string) int) {
+	b.fallbackJudge = strategy
+}
+
+/*
+Sets whether bots play for online players while they are disconnected, 
+otherwise disconnected players sit the round out.
+*/
+func (b *Board) SetBotCover(cover bool) {
+	b.botCover = cover
+}
+
+/*
+Returns a context that is done once the timer runs out, or never if the 
+timer is zero.
+*/
+func timerContext(timer time.Duration) (context.Context, context.CancelFunc) {
+	if timer > 0 {
+		return context.WithTimeout(context.Background(), timer)
+	}
+	return context.WithCancel(context.Background())
+}
+
+/*
+Sets a logger that replaces the host terminal, for games that run without 
+a host player.
+*/
+func (b *Board) SetLogger(logger *log.Logger) {
+	b.logger = logger
 }
 
 /*
-Goes through all players and retrieves which card they want to play. 
+Shows information on the host terminal, or writes it to the log when the 
+board has a logger.
+*/
+func (b *Board) Log(info string) {
+	if b.logger != nil {
+		b.logger.Println(info)
+		return
+	}
+	view.Announce(info)
+}
+
+/*
+Shows an announcement on the host terminal and to all online players.
+*/
+func (b *Board) announce(info string) {
+	b.Log(info)
+	b.MassDisplay(info)
+}
+
+/*
+A card choice made by a player, the card is played from the hand by the 
+collector so that only one goroutine touches the players.
+*/
+type submission struct {
+	playerIndex int
+	cardIndex int
+	err error
+}
+
+/*
+Prompts all players, except the judge, for a card at the same time and 
+collects their submissions. The round continues once every player has 
+submitted or the turn timer runs out, players that miss the deadline have 
+a random card played for them.
+
+Kicked players leave the board before the round starts, and the round waits 
+while the game is paused.
 
 Returns an error if a player plays an invalid card index, this should cause a panic.
 Meaning that this method should not be used to validate user input.
+Returns ErrGameEnded if the host ended the game.
 */
 func (b *Board) ChooseCards() error {
+	pauseErr := b.waitWhilePaused()
+	if pauseErr != nil {
+		return pauseErr
+	}
+	b.applyKicks()
+	if b.CountPlayers() < 2 {
+		b.lock.Lock()
+		b.ended = true
+		b.lock.Unlock()
+		b.announce("Not enough players are left, the game ends.")
+		return ErrGameEnded
+	}
+	controllers := make([]PlayerController, len(b.players))
+	for i := 0; i < len(b.players); i++ {
+		controller, controllerErr := controllerOf(&b.players[i])
+		if controllerErr != nil {
+			return controllerErr
+		}
+		controllers[i] = controller
+	}
+	ctx, cancel := timerContext(b.turnTimeout)
+	defer cancel()
+	b.setRoundCancel(cancel)
+	defer b.setRoundCancel(nil)
+
 	pa := new(PlayedApples)
 	currentJudge := b.CurrentJudgeName()
-	greenApple := b.CurrentGreenApple()
+	b.emit(GameEvent{
+		Type: GAME_ROUND_STARTED,
+		Round: b.round,
+		Judge: currentJudge,
+		GreenApple: b.CurrentGreenApple(),
+	})
+	round := b.roundPayload(ACTION_PLAY_CARD)
+	submissions := make(chan submission, len(b.players))
+	pending := make(map[int]bool)
 	for i := 0; i < len(b.players); i++ {
-		/*
-		If the current player is the judge, display waiting message
-		and go to next player.
-		===============================================================
-		*/
 		if b.players[i].PlayerName() == currentJudge {
-			// skips the judge
-			if b.players[i].Host() {
-				view.WaitPlayerCards()
-			} else if !b.players[i].Host() && !b.players[i].Bot() {
-				b.network.Display(b.players[i].PlayerName(), "Waiting for players to submit cards...")
-			}
+			// the judge waits for the others
+			controllers[i].Notify(NOTICE_WAITING, "Waiting for players to submit cards...")
 			continue
 		}
+		pending[i] = true
+		round.Hand = cardPayloads(b.players[i].hand)
+		go b.collectCard(ctx, i, controllers[i], round, submissions)
+	}
 
-		/*
-		If the current player is a bot, return a random card.
-		===============================================================
-		*/
-		if b.players[i].Bot() {
-			var randomCardIndex int = rand.Intn(b.players[i].CardsInHand())
-			card, cardErr := b.players[i].PlayCard(randomCardIndex)
-			if cardErr != nil {
-				return errors.New("bot tried to play invalid card, " + cardErr.Error())
+	for len(pending) > 0 {
+		select {
+		case sub := <-submissions:
+			delete(pending, sub.playerIndex)
+			if b.Ended() {
+				return ErrGameEnded
 			}
-			pa.SubmitCard(&b.players[i], card)
-		}
-
-		/*
-		If the player is the host, and not a bot, prompt user for a 
-		card.
-		===============================================================
-		*/
-		if b.players[i].Host() && !b.players[i].Bot() {
-			hand := b.players[i].ShowHand()
-			cardIndex := view.ChooseCard(greenApple, hand)
-			card, playerCardErr := b.players[i].PlayCard(cardIndex)
-			if playerCardErr != nil {
-				return errors.New("host tried to play invalid card, " + playerCardErr.Error())
+			if sub.err != nil && b.isKicked(b.players[sub.playerIndex].PlayerName()) {
+				// kicked players leave the board at the start of the next round
+				continue
 			}
-			pa.SubmitCard(&b.players[i], card)
-		}
-
-		/*
-		If the current player is online, send a play message and play 
-		the choosen card.
-		===============================================================
-		*/
-		if !b.players[i].Host() && !b.players[i].Bot() {
-			hand := b.players[i].ShowHand()
-			var validOptions string = fmt.Sprint(len(hand))
-			var prompt string = validOptions + "\n" + "Current green apple - " + greenApple + "\n"
-			for i := 0; i < len(hand); i++ {
-				prompt += "[" + strconv.Itoa(i) + "]" + hand[i] + "\n"
+			if sub.err == ErrTimeout {
+				autoErr := b.autoPlay(pa, sub.playerIndex, " ran out of time, a random card was played for them.")
+				if autoErr != nil {
+					return autoErr
+				}
+				continue
+			}
+			if sub.err == ErrDisconnected {
+				coverErr := b.coverDisconnected(pa, sub.playerIndex)
+				if coverErr != nil {
+					return coverErr
+				}
+				continue
+			}
+			if sub.err != nil {
+				return sub.err
 			}
-			prompt += "Please select a card to play:"
-			cardIndex, err := b.network.Play(b.players[i].PlayerName(), prompt)
-			if err != nil {
-				return err
+			card, cardErr := b.players[sub.playerIndex].PlayCard(sub.cardIndex)
+			if cardErr != nil {
+				return errors.New(b.players[sub.playerIndex].PlayerName() + " tried to play invalid card, " + cardErr.Error())
+			}
+			b.submitCard(pa, &b.players[sub.playerIndex], card)
+		case <-ctx.Done():
+			if b.Ended() {
+				return ErrGameEnded
 			}
-			card, playedCardErr := b.players[i].PlayCard(cardIndex)
-			if playedCardErr != nil {
-				return playedCardErr
+			for index := range pending {
+				autoErr := b.autoPlay(pa, index, " ran out of time, a random card was played for them.")
+				if autoErr != nil {
+					return autoErr
+				}
+				delete(pending, index)
 			}
-			pa.SubmitCard(&b.players[i], card)
 		}
 	}
+	if pa.PlayerCount() == 0 {
+		return errors.New("no cards were submitted")
+	}
 	b.PlayedCards = *pa
 	b.PlayedCards.Shuffle()
 	return nil
 }
 
+/*
+Lets a bot play for a disconnected player if bot cover is enabled, otherwise 
+the player sits the round out. Their hand and score stay on the board until 
+they resume.
+*/
+func (b *Board) coverDisconnected(pa *PlayedApples, index int) error {
+	if b.botCover {
+		return b.autoPlay(pa, index, " is disconnected, a bot played a card for them.")
+	}
+	b.announce(b.players[index].PlayerName() + " is disconnected and sits this round out.")
+	return nil
+}
+
+/*
+Plays a random card for a player that could not play themselves and lets 
+everyone know why.
+
+Returns an error if the player has no cards to play.
+*/
+func (b *Board) autoPlay(pa *PlayedApples, index int, reason string) error {
+	player := &b.players[index]
+	if player.CardsInHand() == 0 {
+		return errors.New(player.PlayerName() + " has no cards to play")
+	}
+	card, cardErr := player.PlayCard(rand.Intn(player.CardsInHand()))
+	if cardErr != nil {
+		return cardErr
+	}
+	b.submitCard(pa, player, card)
+	b.announce(player.PlayerName() + reason)
+	return nil
+}
+
+/*
+Retrieves which card a single player wants to play from their controller 
+and sends the choice to the collector. The round is passed by value so the 
+goroutine never reads the board while the collector plays cards.
+*/
+func (b *Board) collectCard(ctx context.Context, index int, controller PlayerController, round RoundPayload, submissions chan<- submission) {
+	cardIndex, err := controller.ChooseCard(ctx, round)
+	submissions <- submission{playerIndex: index, cardIndex: cardIndex, err: err}
+}
+
+/*
+Adds the card of a player to the submissions of the round.
+*/
+func (b *Board) submitCard(pa *PlayedApples, player *Player, card Card) {
+	pa.SubmitCard(player, card)
+	b.emit(GameEvent{Type: GAME_CARD_SUBMITTED, Round: b.round, PlayerName: player.PlayerName(), Card: card.DisplayCard()})
+}
+
 /*
 Goes through all players and perferms the DrawCard(redApples) method on them, 
 this will cause them to fill their hands to capacity with red apples.
@@ -421,54 +658,96 @@ Calls for the judge to decide the round winner.
 The round winner is given by their index in the 
 PlayersPlayed.pp struct.
 
-Returns an error if no apples have been played.
+If the judge does not decide before the judge timer runs out, the fallback 
+judge picks the winner instead.
+
+The anonymous submissions are revealed to everyone following the game 
+before the judge decides, and the winning card after.
+
+Returns an error if no apples have been played, or ErrGameEnded if the host 
+ended the game.
 */
 func (b *Board) Judge() (int, error) {	
-	var greenApple string = b.CurrentGreenApple()
-	currentJudge := b.players[b.currentJudgeIndex()]
+	pauseErr := b.waitWhilePaused()
+	if pauseErr != nil {
+		return 0, pauseErr
+	}
 	redApples, err := b.PlayedCards.DisplayApples()
 	if err != nil {
 		return 0, errors.New("no apples played")
 	}
-	
-	/*
-	If the current judge is a bot, choose a random card as the winner.
-	=======================================================================
-	*/
-	if currentJudge.Bot() {
-		view.DisplaySubmissions(greenApple, redApples)
-		return rand.Intn(len(b.PlayedCards.pp)), nil
-	}
-
-	/*
-	If the current judge is the host, and not a bot, prompt user for the 
-	winning card.
-	=======================================================================
-	*/
-	if currentJudge.Host() && !currentJudge.Bot() {
-		return view.JudgeCards(greenApple, redApples), nil
-	}
-	
-	/*
-	If the current judge is an online player, prompt that user for the 
-	winning card with a play message.
-	=======================================================================
-	*/
-	if !currentJudge.Host() && !currentJudge.Bot() {
-		var validOptions string = fmt.Sprint(len(redApples)) 
-		var prompt string = validOptions + "\n" + "Current green apple - " + greenApple + "\n"
-		for i := 0; i < len(redApples); i++ {
-			prompt += "[" + strconv.Itoa(i) + "]" + redApples[i] + "\n"
-		}
-		prompt += "Select the winning card:"
-		winner, err := b.network.Play(b.CurrentJudgeName(), prompt)
-		if err != nil {
-			return 0, err
+	b.emit(GameEvent{
+		Type: GAME_SUBMISSIONS_REVEALED,
+		Round: b.round,
+		Judge: b.CurrentJudgeName(),
+		GreenApple: b.CurrentGreenApple(),
+		Cards: redApples,
+	})
+	winner, judgeErr := b.askJudge(redApples)
+	if judgeErr != nil {
+		return 0, judgeErr
+	}
+	winnerName, _ := b.PlayedCards.ShowPlayer(winner)
+	b.emit(GameEvent{
+		Type: GAME_ROUND_WON,
+		Round: b.round,
+		PlayerName: winnerName,
+		Judge: b.CurrentJudgeName(),
+		GreenApple: b.CurrentGreenApple(),
+		Card: redApples[winner],
+	})
+	return winner, nil
+}
+
+/*
+Asks the controller of the current judge for the index of the winning card 
+among redApples.
+*/
+func (b *Board) askJudge(redApples []string) (int, error) {
+	ctx, cancel := timerContext(b.judgeTimeout)
+	defer cancel()
+	b.setJudgeCancel(cancel)
+	defer b.setJudgeCancel(nil)
+
+	currentJudge := &b.players[b.currentJudgeIndex()]
+	controller, controllerErr := controllerOf(currentJudge)
+	if controllerErr != nil {
+		return 0, controllerErr
+	}
+	round := b.roundPayload(ACTION_JUDGE)
+	round.Hand = cardPayloads(currentJudge.hand)
+	round.Submissions = cardPayloads(b.PlayedCards.submittedCards())
+	winner, err := controller.Judge(ctx, round)
+	if err != nil && b.isKicked(b.CurrentJudgeName()) {
+		// kicking closes the connection, so check it before the other reasons
+		return b.judgeInterrupted(redApples, " was kicked")
+	}
+	if err == ErrTimeout {
+		return b.judgeInterrupted(redApples, " ran out of time")
+	}
+	if err == ErrDisconnected {
+		return b.judgeInterrupted(redApples, " is disconnected")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return winner, nil
+}
+
+/*
+Lets everyone know why the judge could not decide and returns the winner 
+picked by the fallback judge, a random submission unless another strategy 
+is set.
+*/
+func (b *Board) judgeFallback(redApples []string, reason string) int {
+	b.announce("The judge " + b.CurrentJudgeName() + reason + ", the winner was picked for them.")
+	if b.fallbackJudge != nil {
+		winner := b.fallbackJudge(redApples)
+		if winner >= 0 && winner < len(redApples) {
+			return winner
 		}
-		return winner, nil
 	}
-	
-	return 0, errors.New("unexpected judge status")
+	return rand.Intn(len(redApples))
 }
 
 /*
@@ -486,10 +765,24 @@ func (b *Board) DiscardRound() error {
 Interface to close network connections.
 */
 func (b *Board) CloseConnections() {
-	b.network.CloseConnections()
+	if b.network != nil {
+		b.network.CloseConnections()
+	}
+}
+
+/*
+Shows information to the spectators only.
+*/
+func (b *Board) spectate(info string) {
+	if b.network != nil {
+		b.network.SpectatorDisplay(info)
+	}
 }
 
 func (b *Board) MassDisplay(info string) error {
+	if b.network == nil {
+		return nil
+	}
 	err := b.network.MassDisplay(info)
 	if err != nil {
 		return err
@@ -498,5 +791,7 @@ func (b *Board) MassDisplay(info string) error {
 }
 
 func (b *Board) GameOver(winner string) {
-	b.network.GameOver(winner)
-}
\ No newline at end of file
+	if b.network != nil {
+		b.network.GameOver(winner)
+	}
+}
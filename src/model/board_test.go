@@ -3,7 +3,9 @@ package model_test
 import (
 	"main/model"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRandomJudge(t *testing.T) {
@@ -448,4 +450,259 @@ func TestJudge(t *testing.T) {
 		t.Log("expected non-empty card")
 		t.FailNow()
 	}
-}
\ No newline at end of file
+}
+
+/*
+Creates a board with one online player, connected through a test network, 
+and three bots. The judge is always one of the bots.
+*/
+func generateOnlineTestBoard(t *testing.T) (*model.Board, *model.MessageReader, *model.MessageWriter) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	t.Cleanup(func() { conn.Close() })
+	return newOnlineTestBoard(t, network), reader, writer
+}
+
+/*
+Creates a board for the online player of the test network and three bots, 
+with a bot as the judge.
+*/
+func newOnlineTestBoard(t *testing.T, network *model.Network) *model.Board {
+	board := newTestBoard(t,
+		*model.NewPlayer("online player 0", false, false, 7),
+		*model.NewPlayer("bot one", false, true, 7),
+		*model.NewPlayer("bot two", false, true, 7),
+		*model.NewPlayer("bot three", false, true, 7))
+	board.SetNetwork(network)
+	for board.CurrentJudgeName() == "online player 0" {
+		board.ItterateJudge()
+	}
+	return board
+}
+
+/*
+Creates a board with the given players and both test decks loaded, with
+full hands and the win condition set.
+*/
+func newTestBoard(t *testing.T, players ...model.Player) *model.Board {
+	board := new(model.Board)
+	for _, player := range players {
+		addErr := board.AddPlayer(player)
+		if addErr != nil {
+			t.Log("test incorrectly configured, ", addErr)
+			t.FailNow()
+		}
+	}
+	redPath, _ := filepath.Abs("../resources/testSetRA.txt")
+	greenPath, _ := filepath.Abs("../resources/testSetGA.txt")
+	loadRedErr := board.LoadRedApples(redPath)
+	loadGreenErr := board.LoadGreenApples(greenPath)
+	if loadRedErr != nil || loadGreenErr != nil {
+		t.Log("test incorrectly configured, ", loadRedErr, loadGreenErr)
+		t.FailNow()
+	}
+	fillErr := board.FillHands()
+	if fillErr != nil {
+		t.Log("could not draw cards, ", fillErr)
+		t.FailNow()
+	}
+	board.SetWinCondition()
+	return board
+}
+
+func TestChooseCardsOnline(t *testing.T) {
+	board, reader, writer := generateOnlineTestBoard(t)
+
+	go func() {
+		msg, err := reader.Receive()
+		if err != nil || msg.Type != model.MSG_PLAY {
+			return
+		}
+		writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "6"})
+	}()
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.PlayedCards.PlayerCount() != 3 {
+		t.Log("expected 3 submissions, received", board.PlayedCards.PlayerCount())
+		t.FailNow()
+	}
+	hand, _ := board.PlayersHand("online player 0")
+	if len(hand) != 6 {
+		t.Log("expected the online player to have played a card")
+		t.FailNow()
+	}
+}
+
+func TestChooseCardsTimeout(t *testing.T) {
+	board, _, _ := generateOnlineTestBoard(t)
+	board.SetTimers(200*time.Millisecond, 0)
+
+	start := time.Now()
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Log("the round did not continue when the timer ran out")
+		t.FailNow()
+	}
+	if board.PlayedCards.PlayerCount() != 3 {
+		t.Log("expected a card to be played for the idle player, received", board.PlayedCards.PlayerCount())
+		t.FailNow()
+	}
+	hand, _ := board.PlayersHand("online player 0")
+	if len(hand) != 6 {
+		t.Log("expected the idle players card to come from their hand")
+		t.FailNow()
+	}
+}
+
+func TestJudgeTimeout(t *testing.T) {
+	board, reader, _ := generateOnlineTestBoard(t)
+	for board.CurrentJudgeName() != "online player 0" {
+		board.ItterateJudge()
+	}
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	board.SetTimers(0, 200*time.Millisecond)
+	board.SetFallbackJudge(func(redApples []string) int {
+		return len(redApples) - 1
+	})
+
+	winner, judgeErr := board.Judge()
+	if judgeErr != nil {
+		t.Log(judgeErr)
+		t.FailNow()
+	}
+	if winner != 2 {
+		t.Log("expected the fallback judge to pick the winner, received", winner)
+		t.FailNow()
+	}
+
+	expected := []string{model.MSG_DISPLAY, model.MSG_PLAY, model.MSG_CANCEL, model.MSG_DISPLAY}
+	for i := 0; i < len(expected); i++ {
+		msg, err := reader.Receive()
+		if err != nil {
+			t.Log("unexpected receive error:", err)
+			t.FailNow()
+		}
+		if msg.Type != expected[i] {
+			t.Log("expected", expected[i], "received", msg.Type)
+			t.FailNow()
+		}
+	}
+}
+
+/*
+Creates an online test board whose online player dropped the connection 
+after the game started.
+*/
+func generateDisconnectedTestBoard(t *testing.T) *model.Board {
+	network, conn, _, _ := generateTestNetwork(t)
+	board := newOnlineTestBoard(t, network)
+	network.StartGame()
+	conn.Close()
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LEFT {
+		t.Log("expected the online player to leave, received", event)
+		t.FailNow()
+	}
+	return board
+}
+
+func TestChooseCardsDisconnectedCover(t *testing.T) {
+	board := generateDisconnectedTestBoard(t)
+	board.SetBotCover(true)
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.PlayedCards.PlayerCount() != 3 {
+		t.Log("expected a bot to play for the disconnected player, received", board.PlayedCards.PlayerCount())
+		t.FailNow()
+	}
+	hand, _ := board.PlayersHand("online player 0")
+	if len(hand) != 6 {
+		t.Log("expected the covered card to come from the disconnected players hand")
+		t.FailNow()
+	}
+}
+
+func TestChooseCardsDisconnectedSitOut(t *testing.T) {
+	board := generateDisconnectedTestBoard(t)
+	board.SetBotCover(false)
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.PlayedCards.PlayerCount() != 2 {
+		t.Log("expected the disconnected player to sit out, received", board.PlayedCards.PlayerCount())
+		t.FailNow()
+	}
+	hand, _ := board.PlayersHand("online player 0")
+	if len(hand) != 7 {
+		t.Log("expected the disconnected player to keep their hand")
+		t.FailNow()
+	}
+}
+
+func TestJudgeSpectated(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+	board := newOnlineTestBoard(t, network)
+	spectator := joinTestSpectator(t, network, conn, "watcher")
+	go func() {
+		msg, err := reader.Receive()
+		for err == nil && msg.Type != model.MSG_PLAY {
+			msg, err = reader.Receive()
+		}
+		writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "0"})
+	}()
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	winner, judgeErr := board.Judge()
+	if judgeErr != nil {
+		t.Log(judgeErr)
+		t.FailNow()
+	}
+	redApples, _ := board.PlayedCards.DisplayApples()
+
+	var seen []string
+	for i := 0; i < 3; i++ {
+		msg, err := spectator.Receive()
+		if err != nil || msg.Type != model.MSG_DISPLAY {
+			t.Log("expected a display message, received", msg, err)
+			t.FailNow()
+		}
+		var display model.DisplayPayload
+		msg.Decode(&display)
+		seen = append(seen, strings.Join(display.Lines, "\n"))
+	}
+	if !strings.Contains(seen[0], "green apple") {
+		t.Log("expected the spectator to see the green apple, received", seen[0])
+		t.FailNow()
+	}
+	if strings.Contains(seen[1], "online player 0") || !strings.Contains(seen[1], redApples[0]) {
+		t.Log("expected the submissions without player names, received", seen[1])
+		t.FailNow()
+	}
+	if !strings.Contains(seen[2], redApples[winner]) {
+		t.Log("expected the judges choice, received", seen[2])
+		t.FailNow()
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 type Card struct {
+	id int
 	cardType string
 	header string
 	description string
@@ -26,6 +27,28 @@ func (c *Card) DisplayCard() string {
 	
 }
 
+/*
+Returns the number that identifies the card within its deck, zero for cards 
+that were not dealt from a deck.
+*/
+func (c *Card) ID() int {
+	return c.id
+}
+
+/*
+Returns the cards header.
+*/
+func (c *Card) Header() string {
+	return c.header
+}
+
+/*
+Returns what the card says.
+*/
+func (c *Card) Description() string {
+	return c.description
+}
+
 /*
 Returns the cards type.
 */
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Longest chat message in characters.
+*/
+const MAX_CHAT_LENGTH = 200
+
+/*
+How many chat messages a player or spectator may send per CHAT_WINDOW, 
+messages above the limit are dropped.
+*/
+const (
+	CHAT_LIMIT = 5
+	CHAT_WINDOW = 5 * time.Second
+)
+
+/*
+How many chat messages wait to be sent to a receiver before new ones are 
+dropped for them.
+*/
+const CHAT_QUEUE = 32
+
+/*
+Decodes a chat message from a player or spectator and relays it, invalid 
+messages and messages above the rate limit of the sender are dropped.
+*/
+func (n *Network) relayChat(sender *PlayerConnection, msg Message) {
+	var chat ChatPayload
+	decodeErr := msg.Decode(&chat)
+	if decodeErr != nil {
+		return
+	}
+	if !sender.allowChat(time.Now()) {
+		return
+	}
+	n.Chat(sender.playerName, chat.Text)
+}
+
+/*
+Counts a chat message against the rate limit of the connection, only the 
+read loop of the connection calls this.
+
+Returns false if the connection already sent CHAT_LIMIT messages in the 
+current window.
+*/
+func (p *PlayerConnection) allowChat(now time.Time) bool {
+	if now.Sub(p.chatWindow) >= CHAT_WINDOW {
+		p.chatWindow = now
+		p.chatCount = 0
+	}
+	if p.chatCount >= CHAT_LIMIT {
+		return false
+	}
+	p.chatCount++
+	return true
+}
+
+/*
+Queues a chat message for the connection, sent in order by its own 
+goroutine so that a receiver that stopped reading only holds up itself. 
+The goroutine only runs while there are messages to send, each write gives 
+up after WRITE_TIMEOUT. If the queue is full the message is dropped.
+*/
+func (p *PlayerConnection) queueChat(chat ChatPayload) {
+	p.chatLock.Lock()
+	defer p.chatLock.Unlock()
+	if len(p.chatQueue) >= CHAT_QUEUE {
+		return
+	}
+	p.chatQueue = append(p.chatQueue, chat)
+	if !p.chatSending {
+		p.chatSending = true
+		go p.sendChats()
+	}
+}
+
+func (p *PlayerConnection) sendChats() {
+	for {
+		p.chatLock.Lock()
+		if len(p.chatQueue) == 0 {
+			p.chatSending = false
+			p.chatLock.Unlock()
+			return
+		}
+		chat := p.chatQueue[0]
+		p.chatQueue = p.chatQueue[1:]
+		p.chatLock.Unlock()
+		p.writer.Send(MSG_CHAT, 0, chat)
+	}
+}
+
+/*
+Queues a chat message for every connected player and spectator that 
+supports chat, with the sender and the current time, and delivers it as an 
+event to the host. The host is not rate limited.
+
+Returns an error if the message is empty or too long.
+*/
+func (n *Network) Chat(from string, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("chat message is empty")
+	}
+	if len([]rune(text)) > MAX_CHAT_LENGTH {
+		return errors.New("chat message can be at most " + strconv.Itoa(MAX_CHAT_LENGTH) + " characters")
+	}
+	chat := ChatPayload{
+		From: from,
+		Text: text,
+		Time: time.Now(),
+	}
+
+	n.lock.Lock()
+	var receivers []*PlayerConnection
+	for i := 0; i < len(n.players); i++ {
+		if n.players[i].connected && n.players[i].supports(FEATURE_CHAT) {
+			receivers = append(receivers, n.players[i])
+		}
+	}
+	for i := 0; i < len(n.spectators); i++ {
+		if n.spectators[i].supports(FEATURE_CHAT) {
+			receivers = append(receivers, n.spectators[i])
+		}
+	}
+	n.lock.Unlock()
+
+	for i := 0; i < len(receivers); i++ {
+		receivers[i].queueChat(chat)
+	}
+	n.emit(NetworkEvent{Type: EVENT_CHAT, PlayerName: from, Text: text})
+	return nil
+}
+
+/*
+Sends a chat message to the host, which relays it to everyone.
+
+Returns an error if there is no host or it does not support chat.
+*/
+func (n *Network) SendChat(text string) error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	if writer == nil {
+		return errors.New("not connected to a host")
+	}
+	if !n.HostSupports(FEATURE_CHAT) {
+		return errors.New("the host does not support chat")
+	}
+	return writer.Send(MSG_CHAT, 0, ChatPayload{Text: text})
+}
@@ -0,0 +1,92 @@
+package model_test
+
+import (
+	"main/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Reads the next message and checks that it is the expected chat message.
+*/
+func receiveChat(t *testing.T, reader *model.MessageReader, from string, text string) {
+	msg, err := reader.Receive()
+	if err != nil || msg.Type != model.MSG_CHAT {
+		t.Log("expected a chat message, received", msg, err)
+		t.FailNow()
+	}
+	var chat model.ChatPayload
+	msg.Decode(&chat)
+	if chat.From != from || chat.Text != text || time.Since(chat.Time) > time.Minute {
+		t.Log("unexpected chat message", chat)
+		t.FailNow()
+	}
+}
+
+func TestChatRelay(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+	_, secondReader, secondWriter := dialTestClient(t, conn)
+	joinTestClient(t, secondReader, secondWriter, "second")
+	awaitEvent(t, network)
+	spectator := joinTestSpectator(t, network, conn, "watcher")
+
+	writer.Send(model.MSG_CHAT, 0, model.ChatPayload{Text: " good luck "})
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_CHAT || event.PlayerName != "online player 0" || event.Text != "good luck" {
+		t.Log("expected a chat event, received", event)
+		t.FailNow()
+	}
+	receiveChat(t, reader, "online player 0", "good luck")
+	receiveChat(t, secondReader, "online player 0", "good luck")
+	receiveChat(t, spectator, "online player 0", "good luck")
+
+	hostErr := network.Chat("host", "welcome")
+	if hostErr != nil {
+		t.Log("unexpected chat error:", hostErr)
+		t.FailNow()
+	}
+	receiveChat(t, reader, "host", "welcome")
+}
+
+func TestChatInvalid(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+
+	invalid := []string{"", "   ", strings.Repeat("a", model.MAX_CHAT_LENGTH+1)}
+	for i := 0; i < len(invalid); i++ {
+		if network.Chat("host", invalid[i]) == nil {
+			t.Log("expected the chat message to be rejected", len(invalid[i]))
+			t.FailNow()
+		}
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+
+	for i := 0; i < model.CHAT_LIMIT*3; i++ {
+		writer.Send(model.MSG_CHAT, 0, model.ChatPayload{Text: "spam"})
+	}
+	// the vote shows that every chat message before it was handled
+	writer.Send(model.MSG_READY, 0, nil)
+	relayed := 0
+	for {
+		event := awaitEvent(t, network)
+		if event.Type == model.EVENT_PLAYER_READY {
+			break
+		}
+		if event.Type == model.EVENT_CHAT {
+			relayed++
+		}
+	}
+	if relayed != model.CHAT_LIMIT {
+		t.Log("expected", model.CHAT_LIMIT, "messages to be relayed, relayed", relayed)
+		t.FailNow()
+	}
+	for i := 0; i < relayed; i++ {
+		receiveChat(t, reader, "online player 0", "spam")
+	}
+}
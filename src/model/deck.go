@@ -16,7 +16,8 @@ type Deck struct {
 
 /*
 Creates a Deck from a text file with the given type "deckType". 
-A Deck contains both the deck and a discard pile for the deck.
+A Deck contains both the deck and a discard pile for the deck. Cards are 
+numbered from 1 in the order of the file, see Card.ID.
 
 Returns an errors on incorrect file path.
 */
@@ -35,6 +36,7 @@ func GenerateDeck(source string, deckType string) (Deck, error) {
 		cardData[1] = strings.Trim(cardData[1], " - ")
 
 		newCard := MintCard(deckType, cardData[0], cardData[1])
+		newCard.id = len(deck.deck) + 1
 		deck.deck = append(deck.deck, newCard)
 	}
 	return deck, nil
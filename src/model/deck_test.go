@@ -51,6 +51,21 @@ func TestGeneratePile(t *testing.T) {
 	}
 }
 
+func TestCardIDs(t *testing.T) {
+	testDeck, deckGenErr := generateTestDeckRA()
+	if deckGenErr != nil {
+		t.Log(deckGenErr)
+		t.FailNow()
+	}
+	for expected := 1; testDeck.CardsLeft() > 0; expected++ {
+		card, _ := testDeck.DrawCard()
+		if card.ID() != expected {
+			t.Log("expected card", card.DisplayCard(), "to have ID", expected, "received", card.ID())
+			t.FailNow()
+		}
+	}
+}
+
 func TestEmptyDeckDraw(t *testing.T) {
 	emptyDeck := new(model.Deck)
 	_, err := emptyDeck.DrawCard()
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+UDP port games are announced on, how often a host announces its game and
+how long a joining player listens for announcements.
+*/
+const (
+	DISCOVERY_PORT = 8079
+	ANNOUNCE_INTERVAL = time.Second
+	DISCOVERY_WINDOW = 2 * time.Second
+)
+
+/*
+Largest announcement that is read, anything longer is cut off and ignored.
+*/
+const MAX_ANNOUNCE_SIZE = 2048
+
+/*
+A game found on the local network, Address is where to join it.
+*/
+type DiscoveredGame struct {
+	AnnouncePayload
+	Address string
+}
+
+/*
+Returns the address announcements are broadcast to on the given port.
+*/
+func BroadcastAddress(port int) string {
+	return net.JoinHostPort(net.IPv4bcast.String(), strconv.Itoa(port))
+}
+
+/*
+Advertises the game on the local network until the context is done, by
+sending an announcement to target every ANNOUNCE_INTERVAL. The target is
+usually the broadcast address, see BroadcastAddress. Every announcement is
+built from the current state of the network, so the player count and open
+seats stay up to date. Port is the port players join on. An announcement 
+that can not be sent, for example while the interface is down, is passed 
+to report once until sending works again, and announcing goes on.
+
+Returns nil once the context is done, or an error if announcing could not 
+be set up.
+*/
+func (n *Network) Announce(ctx context.Context, target string, name string, port int, report func(error)) error {
+	address, resolveErr := net.ResolveUDPAddr("udp4", target)
+	if resolveErr != nil {
+		return resolveErr
+	}
+	conn, listenErr := net.ListenPacket("udp4", ":0")
+	if listenErr != nil {
+		return listenErr
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(ANNOUNCE_INTERVAL)
+	defer ticker.Stop()
+	failing := false
+	for {
+		msg, msgErr := NewMessage(MSG_ANNOUNCE, 0, n.announcement(name, port))
+		if msgErr != nil {
+			return msgErr
+		}
+		data, encodeErr := json.Marshal(msg)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		_, sendErr := conn.WriteTo(data, address)
+		if sendErr != nil && !failing && report != nil {
+			report(sendErr)
+		}
+		failing = sendErr != nil
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Describes the game as it is right now.
+*/
+func (n *Network) announcement(name string, port int) AnnouncePayload {
+	transport := n.Transport()
+	announcement := AnnouncePayload{
+		Name: name,
+		Port: port,
+		Transport: "tcp",
+		TLS: transport.TLS() != nil,
+	}
+	if webSocket, ok := transport.(*WebSocketTransport); ok {
+		announcement.Transport = "websocket"
+		announcement.Path = webSocket.Path()
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	announcement.Password = n.password != ""
+	announcement.Players = len(n.players)
+	announcement.Started = n.started
+	if n.capacity > 0 && !n.started {
+		announcement.Seats = n.capacity - len(n.players)
+	}
+	return announcement
+}
+
+/*
+Listens for games announced on the local network.
+*/
+type Discovery struct {
+	conn net.PacketConn
+}
+
+/*
+Starts listening for announcements on the given UDP address, such as
+":8079". Use port 0 to let the system choose a free port.
+
+Returns an error if the address can not be listened on.
+*/
+func ListenDiscovery(address string) (*Discovery, error) {
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Discovery{conn: conn}, nil
+}
+
+/*
+Returns the address announcements are received on.
+*/
+func (d *Discovery) Addr() net.Addr {
+	return d.conn.LocalAddr()
+}
+
+/*
+Stops listening for announcements.
+*/
+func (d *Discovery) Close() error {
+	return d.conn.Close()
+}
+
+/*
+Collects the games announced during the window, each game once with its
+latest announcement. Datagrams that are not announcements are ignored.
+
+Returns the games sorted by address, or an error if listening fails.
+*/
+func (d *Discovery) Collect(window time.Duration) ([]DiscoveredGame, error) {
+	d.conn.SetReadDeadline(time.Now().Add(window))
+	found := make(map[string]DiscoveredGame)
+	buffer := make([]byte, MAX_ANNOUNCE_SIZE)
+	for {
+		size, from, err := d.conn.ReadFrom(buffer)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var msg Message
+		if json.Unmarshal(buffer[:size], &msg) != nil || msg.Type != MSG_ANNOUNCE {
+			continue
+		}
+		var announcement AnnouncePayload
+		if msg.Decode(&announcement) != nil || announcement.Port <= 0 || announcement.Port > 65535 {
+			continue
+		}
+		address := net.JoinHostPort(addressIP(from), strconv.Itoa(announcement.Port))
+		found[address] = DiscoveredGame{AnnouncePayload: announcement, Address: address}
+	}
+
+	games := make([]DiscoveredGame, 0, len(found))
+	for _, game := range found {
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].Address < games[j].Address
+	})
+	return games, nil
+}
+
+/*
+Listens on address for the length of the window and returns the games
+announced in the meantime, see Discovery.Collect.
+
+Returns an error if the address can not be listened on.
+*/
+func DiscoverGames(address string, window time.Duration) ([]DiscoveredGame, error) {
+	discovery, listenErr := ListenDiscovery(address)
+	if listenErr != nil {
+		return nil, listenErr
+	}
+	defer discovery.Close()
+	return discovery.Collect(window)
+}
@@ -0,0 +1,125 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*
+Long enough to receive the first announcement, which is sent at once.
+*/
+const ANNOUNCE_WAIT = 500 * time.Millisecond
+
+func TestDiscoverAnnouncedGame(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.SetCapacity(3)
+	network.SetPassword("secret")
+	_, portText, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	port, _ := strconv.Atoi(portText)
+
+	discovery, listenErr := model.ListenDiscovery("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen for announcements:", listenErr)
+		t.FailNow()
+	}
+	defer discovery.Close()
+
+	// stray datagrams on the port are ignored
+	stray, dialErr := net.Dial("udp4", discovery.Addr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	stray.Write([]byte("not an announcement"))
+	stray.Close()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	announced := make(chan error, 1)
+	go func() {
+		announced <- network.Announce(ctx, discovery.Addr().String(), "alice's game", port, nil)
+	}()
+
+	games, collectErr := discovery.Collect(ANNOUNCE_WAIT)
+	if collectErr != nil {
+		t.Log("unexpected collect error:", collectErr)
+		t.FailNow()
+	}
+	if len(games) != 1 {
+		t.Log("expected one game, found", len(games))
+		t.FailNow()
+	}
+	game := games[0]
+	if game.Name != "alice's game" || game.Address != net.JoinHostPort("127.0.0.1", portText) {
+		t.Log("expected the game to be found at the host address, found", game)
+		t.FailNow()
+	}
+	if game.Transport != "tcp" || game.TLS || !game.Password || game.Players != 1 || game.Seats != 2 || game.Started {
+		t.Log("expected the announcement to describe the game, found", game.AnnouncePayload)
+		t.FailNow()
+	}
+
+	stop()
+	if announceErr := <-announced; announceErr != nil {
+		t.Log("expected announcing to stop without error:", announceErr)
+		t.FailNow()
+	}
+}
+
+func TestDiscoverNoGames(t *testing.T) {
+	start := time.Now()
+	games, discoverErr := model.DiscoverGames("127.0.0.1:0", 100*time.Millisecond)
+	if discoverErr != nil {
+		t.Log("unexpected discover error:", discoverErr)
+		t.FailNow()
+	}
+	if len(games) != 0 {
+		t.Log("expected no games, found", len(games))
+		t.FailNow()
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Log("expected to listen for the whole window")
+		t.FailNow()
+	}
+}
+
+func TestAnnounceKeepsGoing(t *testing.T) {
+	network := new(model.Network)
+	reported := make(chan error, 4)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	announced := make(chan error, 1)
+	go func() {
+		// nothing can be sent to port 0, so every announcement fails
+		announced <- network.Announce(ctx, "127.0.0.1:0", "broken game", 8080, func(err error) {
+			reported <- err
+		})
+	}()
+
+	select {
+	case <-reported:
+	case <-time.After(ANNOUNCE_WAIT):
+		t.Log("expected the failed announcement to be reported")
+		t.FailNow()
+	}
+	select {
+	case announceErr := <-announced:
+		t.Log("expected announcing to go on after a failed send, stopped with", announceErr)
+		t.FailNow()
+	case <-time.After(model.ANNOUNCE_INTERVAL + ANNOUNCE_WAIT):
+	}
+	if len(reported) != 0 {
+		t.Log("expected the failure to be reported once until sending works again")
+		t.FailNow()
+	}
+	stop()
+	if announceErr := <-announced; announceErr != nil {
+		t.Log("expected announcing to stop without error:", announceErr)
+		t.FailNow()
+	}
+}
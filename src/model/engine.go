@@ -0,0 +1,311 @@
+package model
+
+import (
+	"sync"
+)
+
+/*
+The phases of a game, a round goes from dealing through submitting,
+judging and scoring back to dealing until someone wins. A game fails if an 
+action breaks off after it changed the board.
+*/
+type Phase string
+
+const (
+	PHASE_LOBBY Phase = "lobby"
+	PHASE_DEALING Phase = "dealing"
+	PHASE_SUBMITTING Phase = "submitting"
+	PHASE_JUDGING Phase = "judging"
+	PHASE_SCORING Phase = "scoring"
+	PHASE_GAME_OVER Phase = "game over"
+	PHASE_FAILED Phase = "failed"
+)
+
+/*
+Returned when an action is not allowed in the current phase of the game,
+Expected is the phase the action belongs to. Busy is set if another action 
+of the phase is still running.
+*/
+type PhaseError struct {
+	Action string
+	Phase Phase
+	Expected Phase
+	Busy bool
+}
+
+func (e *PhaseError) Error() string {
+	if e.Busy {
+		return "can not " + e.Action + " while another action of " + string(e.Phase) + " is running"
+	}
+	return "can not " + e.Action + " during " + string(e.Phase) + ", only during " + string(e.Expected)
+}
+
+/*
+Runs the rounds of a game on a board in order. Every action belongs to one
+phase and moves the game on to the next, only one action runs at a time. 
+Actions out of order are rejected with a PhaseError and leave the board 
+untouched.
+
+An action that fails after it changed the board, such as a round where a 
+player played an invalid card, leaves the game failed since the round can 
+not be played again. Actions that fail before changing the board can be 
+retried.
+*/
+type Engine struct {
+	board *Board
+	lock sync.Mutex
+	phase Phase
+	busy bool
+	winningCard int
+}
+
+/*
+Creates an engine for a board that is set up, with decks loaded, hands
+dealt, a judge and a win condition. The game waits in the lobby until it
+is started.
+*/
+func NewEngine(board *Board) *Engine {
+	return &Engine{
+		board: board,
+		phase: PHASE_LOBBY,
+	}
+}
+
+/*
+Returns the board the engine plays on.
+*/
+func (e *Engine) Board() *Board {
+	return e.board
+}
+
+/*
+Returns the current phase of the game.
+*/
+func (e *Engine) Phase() Phase {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.phase
+}
+
+/*
+Claims the game for an action, checking the phase and marking the action 
+as running in one step so that two calls can not both run it. Every action 
+that began has to end with a call to finish.
+
+Returns a PhaseError unless the game is in the expected phase and no other 
+action is running.
+*/
+func (e *Engine) begin(action string, expected Phase) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.phase != expected {
+		return &PhaseError{Action: action, Phase: e.phase, Expected: expected}
+	}
+	if e.busy {
+		return &PhaseError{Action: action, Phase: e.phase, Expected: expected, Busy: true}
+	}
+	e.busy = true
+	return nil
+}
+
+/*
+Ends the running action and moves the game on to phase.
+*/
+func (e *Engine) finish(phase Phase) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.phase = phase
+	e.busy = false
+}
+
+/*
+Leaves the lobby. A game that is already won, such as a restored one, is
+over right away.
+
+Returns a PhaseError outside the lobby, or an error if the board has no
+valid win condition.
+*/
+func (e *Engine) Start() error {
+	phaseErr := e.begin("start the game", PHASE_LOBBY)
+	if phaseErr != nil {
+		return phaseErr
+	}
+	nextErr := e.nextRound()
+	if nextErr != nil {
+		// nothing changed, the game can be started once the board is fixed
+		e.finish(PHASE_LOBBY)
+	}
+	return nextErr
+}
+
+/*
+Starts a round by putting a green apple on the board.
+
+Returns a PhaseError outside of dealing, ErrGameEnded if the host ended
+the game or an error if no green apple can be drawn.
+*/
+func (e *Engine) Deal() error {
+	phaseErr := e.begin("deal", PHASE_DEALING)
+	if phaseErr != nil {
+		return phaseErr
+	}
+	if e.board.Ended() {
+		e.gameOver()
+		return ErrGameEnded
+	}
+	drawErr := e.board.DrawGreenApple()
+	if drawErr != nil {
+		// no card was drawn, so dealing can be tried again
+		e.finish(PHASE_DEALING)
+		return drawErr
+	}
+	e.finish(PHASE_SUBMITTING)
+	return nil
+}
+
+/*
+Collects a card from every player except the judge, see Board.ChooseCards.
+
+Returns a PhaseError outside of submitting, or the error of ChooseCards.
+*/
+func (e *Engine) Submit() error {
+	phaseErr := e.begin("submit cards", PHASE_SUBMITTING)
+	if phaseErr != nil {
+		return phaseErr
+	}
+	chooseErr := e.board.ChooseCards()
+	if chooseErr == ErrGameEnded {
+		e.gameOver()
+		return chooseErr
+	}
+	if chooseErr != nil {
+		e.finish(PHASE_FAILED)
+		return chooseErr
+	}
+	e.finish(PHASE_JUDGING)
+	return nil
+}
+
+/*
+Lets the judge pick the winning card, see Board.Judge.
+
+Returns the name of the player who won the round, a PhaseError outside of
+judging, or the error of Judge.
+*/
+func (e *Engine) Judge() (string, error) {
+	phaseErr := e.begin("judge", PHASE_JUDGING)
+	if phaseErr != nil {
+		return "", phaseErr
+	}
+	winningCard, judgeErr := e.board.Judge()
+	if judgeErr == ErrGameEnded {
+		e.gameOver()
+		return "", judgeErr
+	}
+	if judgeErr != nil {
+		e.finish(PHASE_FAILED)
+		return "", judgeErr
+	}
+	winner, indexErr := e.board.PlayedCards.ShowPlayer(winningCard)
+	if indexErr != nil {
+		e.finish(PHASE_FAILED)
+		return "", indexErr
+	}
+	e.winningCard = winningCard
+	e.finish(PHASE_SCORING)
+	return winner, nil
+}
+
+/*
+Awards the green apple to the winner of the round, discards the played
+cards, refills the hands and passes on the judge. The game is over once
+someone has won or the host ended it, otherwise the next round is dealt.
+
+Returns a PhaseError outside of scoring, or the first error encountered.
+*/
+func (e *Engine) Score() error {
+	phaseErr := e.begin("score", PHASE_SCORING)
+	if phaseErr != nil {
+		return phaseErr
+	}
+	scoreErr := e.score()
+	if scoreErr != nil {
+		// the green apple may already be awarded, scoring again would award it twice
+		e.finish(PHASE_FAILED)
+		return scoreErr
+	}
+	return nil
+}
+
+func (e *Engine) score() error {
+	winner, indexErr := e.board.PlayedCards.ShowPlayer(e.winningCard)
+	if indexErr != nil {
+		return indexErr
+	}
+	greenApple, pickErr := e.board.PickUpGreenApple()
+	if pickErr != nil {
+		return pickErr
+	}
+	e.board.AwardScore(winner, greenApple)
+	discardErr := e.board.DiscardRound()
+	if discardErr != nil {
+		return discardErr
+	}
+	drawErr := e.board.FillHands()
+	if drawErr != nil {
+		return drawErr
+	}
+	e.board.ItterateJudge()
+	return e.nextRound()
+}
+
+/*
+Ends the game if someone has won or the host ended it, otherwise gets
+ready to deal the next round. Finishes the running action unless it 
+returns an error.
+*/
+func (e *Engine) nextRound() error {
+	won, winErr := e.board.GameWinner()
+	if winErr != nil {
+		return winErr
+	}
+	if won || e.board.Ended() {
+		e.gameOver()
+		return nil
+	}
+	e.finish(PHASE_DEALING)
+	return nil
+}
+
+/*
+Ends the game, finishing the running action, and lets everyone know who 
+won it.
+*/
+func (e *Engine) gameOver() {
+	e.finish(PHASE_GAME_OVER)
+	winner, winnerErr := e.winner()
+	if winnerErr == nil {
+		e.board.emit(GameEvent{Type: GAME_WON, Round: e.board.round, PlayerName: winner.PlayerName()})
+	}
+}
+
+/*
+Returns the winner of the game, the leader if the host ended the game
+early.
+
+Returns a PhaseError before the game is over.
+*/
+func (e *Engine) Winner() (Player, error) {
+	phase := e.Phase()
+	if phase != PHASE_GAME_OVER {
+		return Player{}, &PhaseError{Action: "pick the winner", Phase: phase, Expected: PHASE_GAME_OVER}
+	}
+	return e.winner()
+}
+
+func (e *Engine) winner() (Player, error) {
+	if e.board.Ended() {
+		return e.board.Leader()
+	}
+	return e.board.WhoWonGame()
+}
@@ -0,0 +1,164 @@
+package model_test
+
+import (
+	"errors"
+	"main/model"
+	"testing"
+)
+
+/*
+Checks that err is a PhaseError for an action during phase.
+*/
+func expectPhaseError(t *testing.T, err error, phase model.Phase) {
+	var phaseErr *model.PhaseError
+	if !errors.As(err, &phaseErr) || phaseErr.Phase != phase {
+		t.Log("expected the action to be rejected during", phase, "received", err)
+		t.FailNow()
+	}
+}
+
+func TestEngineRound(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	if engine.Phase() != model.PHASE_LOBBY {
+		t.Log("expected the game to start in the lobby, phase", engine.Phase())
+		t.FailNow()
+	}
+	expectPhaseError(t, engine.Deal(), model.PHASE_LOBBY)
+
+	startErr := engine.Start()
+	if startErr != nil || engine.Phase() != model.PHASE_DEALING {
+		t.Log("expected the game to start dealing:", startErr, engine.Phase())
+		t.FailNow()
+	}
+	expectPhaseError(t, engine.Start(), model.PHASE_DEALING)
+	expectPhaseError(t, engine.Submit(), model.PHASE_DEALING)
+
+	dealErr := engine.Deal()
+	if dealErr != nil || engine.Phase() != model.PHASE_SUBMITTING || board.Round() != 1 {
+		t.Log("expected the first round to be dealt:", dealErr, engine.Phase())
+		t.FailNow()
+	}
+	_, judgeErr := engine.Judge()
+	expectPhaseError(t, judgeErr, model.PHASE_SUBMITTING)
+
+	submitErr := engine.Submit()
+	if submitErr != nil || engine.Phase() != model.PHASE_JUDGING {
+		t.Log("expected the cards to be submitted:", submitErr, engine.Phase())
+		t.FailNow()
+	}
+	expectPhaseError(t, engine.Score(), model.PHASE_JUDGING)
+
+	winner, judgeErr := engine.Judge()
+	if judgeErr != nil || engine.Phase() != model.PHASE_SCORING {
+		t.Log("expected the judge to pick a winner:", judgeErr, engine.Phase())
+		t.FailNow()
+	}
+	scoreErr := engine.Score()
+	if scoreErr != nil || engine.Phase() != model.PHASE_DEALING {
+		t.Log("expected the next round to be dealt after scoring:", scoreErr, engine.Phase())
+		t.FailNow()
+	}
+	leader, _ := board.Leader()
+	if leader.PlayerName() != winner || leader.Score() != 1 {
+		t.Log("expected", winner, "to be awarded the green apple")
+		t.FailNow()
+	}
+	if board.CurrentJudgeName() != "scripted 1" || !board.AllHandsFull() {
+		t.Log("expected the hands to be refilled and the judge to be passed on")
+		t.FailNow()
+	}
+	_, winnerErr := engine.Winner()
+	expectPhaseError(t, winnerErr, model.PHASE_DEALING)
+}
+
+func TestEngineGameOver(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	engine.Start()
+	for rounds := 0; engine.Phase() != model.PHASE_GAME_OVER; rounds++ {
+		if rounds > 100 {
+			t.Log("expected the game to be won")
+			t.FailNow()
+		}
+		dealErr := engine.Deal()
+		submitErr := engine.Submit()
+		_, judgeErr := engine.Judge()
+		scoreErr := engine.Score()
+		if dealErr != nil || submitErr != nil || judgeErr != nil || scoreErr != nil {
+			t.Log("unexpected round error:", dealErr, submitErr, judgeErr, scoreErr)
+			t.FailNow()
+		}
+	}
+	winner, winnerErr := engine.Winner()
+	if winnerErr != nil || winner.Score() < board.GetWinCondition() {
+		t.Log("expected the winner to have reached the win condition:", winnerErr)
+		t.FailNow()
+	}
+	expectPhaseError(t, engine.Deal(), model.PHASE_GAME_OVER)
+}
+
+func TestEngineEnded(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	engine.Start()
+	board.End()
+	if engine.Deal() != model.ErrGameEnded || engine.Phase() != model.PHASE_GAME_OVER {
+		t.Log("expected the game to be over once the host ended it, phase", engine.Phase())
+		t.FailNow()
+	}
+	_, winnerErr := engine.Winner()
+	if winnerErr != nil {
+		t.Log("expected the leader to win the ended game:", winnerErr)
+		t.FailNow()
+	}
+}
+
+func TestEngineConcurrentActions(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	engine.Start()
+	engine.Deal()
+	engine.Submit()
+	engine.Judge()
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- engine.Score()
+		}()
+	}
+	var rejected int
+	for i := 0; i < 2; i++ {
+		err := <-results
+		var phaseErr *model.PhaseError
+		if errors.As(err, &phaseErr) {
+			rejected++
+		} else if err != nil {
+			t.Log("unexpected score error:", err)
+			t.FailNow()
+		}
+	}
+	leader, _ := board.Leader()
+	if rejected != 1 || leader.Score() != 1 {
+		t.Log("expected exactly one score to award the green apple, rejected", rejected, "leader score", leader.Score())
+		t.FailNow()
+	}
+}
+
+func TestEngineFailed(t *testing.T) {
+	board, controllers := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	engine.Start()
+	engine.Deal()
+	engine.Submit()
+	controllers[0].judgeErr = errors.New("judge broke down")
+	_, judgeErr := engine.Judge()
+	if judgeErr == nil || engine.Phase() != model.PHASE_FAILED {
+		t.Log("expected the game to fail with the judge:", judgeErr, engine.Phase())
+		t.FailNow()
+	}
+	expectPhaseError(t, engine.Score(), model.PHASE_FAILED)
+	_, retryErr := engine.Judge()
+	expectPhaseError(t, retryErr, model.PHASE_FAILED)
+}
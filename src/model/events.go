@@ -0,0 +1,124 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+/*
+The kinds of things that happen during a game.
+*/
+type GameEventType string
+
+const (
+	GAME_PLAYER_JOINED GameEventType = "player joined"
+	GAME_ROUND_STARTED GameEventType = "round started"
+	GAME_CARD_SUBMITTED GameEventType = "card submitted"
+	GAME_SUBMISSIONS_REVEALED GameEventType = "submissions revealed"
+	GAME_ROUND_WON GameEventType = "round won"
+	GAME_JUDGE_ROTATED GameEventType = "judge rotated"
+	GAME_WON GameEventType = "game won"
+	GAME_PLAYER_DISCONNECTED GameEventType = "player disconnected"
+)
+
+/*
+Something that happened during a game. PlayerName is the player the event
+is about, the one who joined, submitted, won or disconnected. Card is the
+submitted or winning card and Cards the revealed submissions. Fields that
+do not apply to the type are left empty.
+*/
+type GameEvent struct {
+	Type GameEventType
+	Time time.Time
+	Round int
+	PlayerName string
+	Judge string
+	GreenApple string
+	Card string
+	Cards []string
+}
+
+/*
+A handler subscribed to the events of a board.
+*/
+type subscriber struct {
+	id int
+	handler func(event GameEvent)
+}
+
+/*
+Calls handler with every event of the game from now on, and returns a
+function that stops the calls. Handlers are called in the order they
+subscribed, on the goroutine the event happens on, so they must be safe for
+concurrent use and should return quickly.
+*/
+func (b *Board) Subscribe(handler func(event GameEvent)) func() {
+	b.eventLock.Lock()
+	defer b.eventLock.Unlock()
+	id := b.nextSubscriber
+	b.nextSubscriber++
+	b.subscribers = append(b.subscribers, subscriber{id: id, handler: handler})
+	return func() {
+		b.eventLock.Lock()
+		defer b.eventLock.Unlock()
+		for i := 0; i < len(b.subscribers); i++ {
+			if b.subscribers[i].id == id {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+/*
+Delivers an event to every subscriber.
+*/
+func (b *Board) emit(event GameEvent) {
+	event.Time = time.Now()
+	b.eventLock.Lock()
+	subscribers := b.subscribers
+	b.eventLock.Unlock()
+	for i := 0; i < len(subscribers); i++ {
+		subscribers[i].handler(event)
+	}
+}
+
+/*
+Turns network events into game events, observed while the board has a 
+network.
+*/
+func (b *Board) networkEvent(event NetworkEvent) {
+	if event.Type == EVENT_PLAYER_LEFT {
+		b.emit(GameEvent{Type: GAME_PLAYER_DISCONNECTED, PlayerName: event.PlayerName})
+	}
+}
+
+/*
+Shows the spectators how the round goes and tells everyone online who won 
+the game, subscribed while the board has a network. Submitted cards stay 
+hidden until the judge sees them.
+*/
+func (b *Board) broadcast(event GameEvent) {
+	switch event.Type {
+	case GAME_ROUND_STARTED:
+		b.spectate("The green apple is " + event.GreenApple + ", the judge is " + event.Judge)
+	case GAME_SUBMISSIONS_REVEALED:
+		submissions := "The submissions for " + event.GreenApple + " are"
+		for i := 0; i < len(event.Cards); i++ {
+			submissions += "\n[" + strconv.Itoa(i) + "]" + event.Cards[i]
+		}
+		b.spectate(submissions)
+	case GAME_ROUND_WON:
+		b.spectate("The judge " + event.Judge + " picked " + event.Card)
+	case GAME_WON:
+		b.GameOver(event.PlayerName)
+	}
+}
+
+/*
+Calls handler with every event of the game on the board, see
+Board.Subscribe.
+*/
+func (e *Engine) Subscribe(handler func(event GameEvent)) func() {
+	return e.board.Subscribe(handler)
+}
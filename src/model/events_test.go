@@ -0,0 +1,177 @@
+package model_test
+
+import (
+	"main/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Collects the events of a board for a test.
+*/
+type eventLog struct {
+	lock sync.Mutex
+	events []model.GameEvent
+}
+
+func (l *eventLog) record(event model.GameEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) types() []model.GameEventType {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var types []model.GameEventType
+	for _, event := range l.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestRoundEvents(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	log := new(eventLog)
+	unsubscribe := engine.Subscribe(log.record)
+
+	engine.Start()
+	engine.Deal()
+	engine.Submit()
+	winner, _ := engine.Judge()
+	scoreErr := engine.Score()
+	if scoreErr != nil {
+		t.Log("unexpected round error:", scoreErr)
+		t.FailNow()
+	}
+
+	expected := []model.GameEventType{
+		model.GAME_ROUND_STARTED,
+		model.GAME_CARD_SUBMITTED,
+		model.GAME_CARD_SUBMITTED,
+		model.GAME_CARD_SUBMITTED,
+		model.GAME_SUBMISSIONS_REVEALED,
+		model.GAME_ROUND_WON,
+		model.GAME_JUDGE_ROTATED,
+	}
+	types := log.types()
+	if len(types) != len(expected) {
+		t.Log("expected the events of one round, received", types)
+		t.FailNow()
+	}
+	for i := 0; i < len(expected); i++ {
+		if types[i] != expected[i] {
+			t.Log("expected", expected[i], "received", types[i])
+			t.FailNow()
+		}
+	}
+	started, revealed, won, rotated := log.events[0], log.events[4], log.events[5], log.events[6]
+	if started.Round != 1 || started.Judge != "scripted 0" || started.GreenApple == "" {
+		t.Log("expected the round, judge and green apple when the round starts, received", started)
+		t.FailNow()
+	}
+	if won.PlayerName != winner || won.Card != revealed.Cards[1] || won.Judge != "scripted 0" {
+		t.Log("expected the winner and the winning card, received", won)
+		t.FailNow()
+	}
+	if rotated.Judge != "scripted 1" || won.Time.IsZero() {
+		t.Log("expected the next judge, received", rotated)
+		t.FailNow()
+	}
+
+	unsubscribe()
+	engine.Deal()
+	if len(log.types()) != len(expected) {
+		t.Log("expected no events after unsubscribing")
+		t.FailNow()
+	}
+}
+
+func TestGameWonEvent(t *testing.T) {
+	board, _ := newScriptedBoard(t)
+	engine := model.NewEngine(board)
+	stats := model.NewGameStats()
+	engine.Subscribe(stats.Record)
+
+	engine.Start()
+	for engine.Phase() != model.PHASE_GAME_OVER {
+		engine.Deal()
+		engine.Submit()
+		engine.Judge()
+		scoreErr := engine.Score()
+		if scoreErr != nil {
+			t.Log("unexpected round error:", scoreErr)
+			t.FailNow()
+		}
+	}
+	winner, _ := engine.Winner()
+	if stats.Winner() != winner.PlayerName() || stats.RoundsWon(winner.PlayerName()) != winner.Score() {
+		t.Log("expected the statistics to follow the game, winner", stats.Winner())
+		t.FailNow()
+	}
+	submitted := 0
+	for i := 0; i < 4; i++ {
+		submitted += stats.Submitted("scripted " + string(rune('0'+i)))
+	}
+	if submitted != 3*stats.Rounds() {
+		t.Log("expected three cards per round, received", submitted, "in", stats.Rounds(), "rounds")
+		t.FailNow()
+	}
+}
+
+func TestPlayerDisconnectedEvent(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	board := newOnlineTestBoard(t, network)
+	network.StartGame()
+	disconnected := make(chan model.GameEvent, 1)
+	board.Subscribe(func(event model.GameEvent) {
+		if event.Type == model.GAME_PLAYER_DISCONNECTED {
+			disconnected <- event
+		}
+	})
+
+	conn.Close()
+	select {
+	case event := <-disconnected:
+		if event.PlayerName != "online player 0" {
+			t.Log("expected the online player to disconnect, received", event.PlayerName)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("timed out waiting for the disconnect")
+		t.FailNow()
+	}
+}
+
+func TestGameStatsSummary(t *testing.T) {
+	stats := model.NewGameStats()
+	events := []model.GameEvent{
+		{Type: model.GAME_PLAYER_JOINED, PlayerName: "alice"},
+		{Type: model.GAME_PLAYER_JOINED, PlayerName: "bob"},
+		{Type: model.GAME_ROUND_STARTED, Round: 1},
+		{Type: model.GAME_CARD_SUBMITTED, PlayerName: "bob"},
+		{Type: model.GAME_ROUND_WON, PlayerName: "bob"},
+		{Type: model.GAME_PLAYER_DISCONNECTED, PlayerName: "bob"},
+	}
+	for _, event := range events {
+		stats.Record(event)
+	}
+	summary := stats.Summary()
+	expected := []string{
+		"1 rounds played",
+		"alice: won 0 rounds, submitted 0 cards",
+		"bob: won 1 rounds, submitted 1 cards, lost the connection 1 times",
+	}
+	if len(summary) != len(expected) {
+		t.Log("expected a line for the game and every player, received", summary)
+		t.FailNow()
+	}
+	for i := 0; i < len(expected); i++ {
+		if summary[i] != expected[i] {
+			t.Log("expected", expected[i], "received", summary[i])
+			t.FailNow()
+		}
+	}
+}
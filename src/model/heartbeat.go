@@ -0,0 +1,135 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/*
+How responsive a players connection is, as shown on the scoreboard.
+*/
+type ConnectionState string
+
+const (
+	STATE_CONNECTED ConnectionState = "connected"
+	STATE_LAGGING ConnectionState = "lagging"
+	STATE_LOST ConnectionState = "lost"
+)
+
+/*
+Sets how often players are pinged and how many pings in a row they may 
+leave unanswered before their connection is considered lost. An interval of 
+zero turns the heartbeat off, it must be set before Serve is called.
+*/
+func (n *Network) SetHeartbeat(interval time.Duration, maxMissed int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+	n.heartbeat = interval
+	n.maxMissed = maxMissed
+}
+
+/*
+Pings every connected player each interval until the context is done. A 
+player that misses a ping is lagging, once the miss threshold is reached 
+their connection is closed and the seat is handled like any other dropped 
+connection.
+*/
+func (n *Network) heartbeatLoop(ctx context.Context) {
+	n.lock.Lock()
+	interval := n.heartbeat
+	n.lock.Unlock()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.pingPlayers()
+		}
+	}
+}
+
+/*
+Sends one round of pings, closing the connections that missed too many.
+*/
+func (n *Network) pingPlayers() {
+	var ping, lagging, lost []*PlayerConnection
+	n.lock.Lock()
+	for i := 0; i < len(n.players); i++ {
+		player := n.players[i]
+		if !player.connected {
+			continue
+		}
+		if player.missed >= n.maxMissed {
+			lost = append(lost, player)
+			continue
+		}
+		if player.missed == 1 {
+			lagging = append(lagging, player)
+		}
+		player.missed++
+		ping = append(ping, player)
+	}
+	n.lock.Unlock()
+
+	for i := 0; i < len(lagging); i++ {
+		n.emit(NetworkEvent{Type: EVENT_PLAYER_LAGGING, PlayerName: lagging[i].playerName})
+	}
+	for i := 0; i < len(lost); i++ {
+		n.emit(NetworkEvent{Type: EVENT_PLAYER_LOST, PlayerName: lost[i].playerName})
+		// the read loop fails on the closed connection and disconnects the player
+		lost[i].conn.Close()
+	}
+	for i := 0; i < len(ping); i++ {
+		ping[i].writer.Send(MSG_PING, n.nextRequestID(), nil)
+	}
+}
+
+/*
+Records that the player answered a ping.
+*/
+func (n *Network) pong(player *PlayerConnection) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	player.missed = 0
+}
+
+/*
+Returns the connection state of the named player.
+
+Returns an error if there is no such player.
+*/
+func (n *Network) ConnectionState(name string) (ConnectionState, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	index := n.indexOf(name)
+	if index < 0 {
+		return STATE_LOST, errors.New("did not find online player by name")
+	}
+	player := n.players[index]
+	if !player.connected {
+		return STATE_LOST, nil
+	}
+	if player.missed > 1 {
+		return STATE_LAGGING, nil
+	}
+	return STATE_CONNECTED, nil
+}
+
+/*
+Answers a ping from the host.
+*/
+func (n *Network) Pong(id int) error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	return writer.Send(MSG_PONG, id, nil)
+}
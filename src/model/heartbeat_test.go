@@ -0,0 +1,92 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"net"
+	"testing"
+	"time"
+)
+
+/*
+Starts a network with a fast heartbeat and joins a raw client to it.
+*/
+func generateHeartbeatNetwork(t *testing.T, maxMissed int) (*model.Network, net.Conn, *model.MessageReader, *model.MessageWriter) {
+	network := new(model.Network)
+	network.SetHeartbeat(50*time.Millisecond, maxMissed)
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	address, listenErr := network.Listener(ctx, "127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("incorrect test config,", listenErr)
+		t.FailNow()
+	}
+	conn, dialErr := model.NewTCPTransport().Dial(address.String())
+	if dialErr != nil {
+		t.Log("incorrect test config,", dialErr)
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader, writer := model.NewMessageReader(conn), model.NewMessageWriter(conn)
+	accepted, reason := joinTestClient(t, reader, writer, "pinged")
+	if !accepted {
+		t.Log("test client was rejected:", reason)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+	return network, conn, reader, writer
+}
+
+func TestHeartbeatAnswered(t *testing.T) {
+	network, _, reader, writer := generateHeartbeatNetwork(t, 2)
+	for i := 0; i < 5; i++ {
+		msg, err := reader.Receive()
+		if err != nil {
+			t.Log("unexpected receive error:", err)
+			t.FailNow()
+		}
+		if msg.Type != model.MSG_PING {
+			t.Log("expected a ping, received", msg.Type)
+			t.FailNow()
+		}
+		writer.Send(model.MSG_PONG, msg.ID, nil)
+	}
+	state, stateErr := network.ConnectionState("pinged")
+	if stateErr != nil || state != model.STATE_CONNECTED {
+		t.Log("expected an answering player to stay connected, received", state, stateErr)
+		t.FailNow()
+	}
+}
+
+func TestHeartbeatMissed(t *testing.T) {
+	network, _, _, _ := generateHeartbeatNetwork(t, 3)
+	network.StartGame()
+
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LAGGING || event.PlayerName != "pinged" {
+		t.Log("expected a lagging event, received", event)
+		t.FailNow()
+	}
+	state, _ := network.ConnectionState("pinged")
+	if state != model.STATE_LAGGING {
+		t.Log("expected the player to be lagging, received", state)
+		t.FailNow()
+	}
+
+	event = awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LOST || event.PlayerName != "pinged" {
+		t.Log("expected a lost event, received", event)
+		t.FailNow()
+	}
+	event = awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LEFT {
+		t.Log("expected the connection to be dropped, received", event)
+		t.FailNow()
+	}
+	state, _ = network.ConnectionState("pinged")
+	if state != model.STATE_LOST {
+		t.Log("expected the player to be lost, received", state)
+		t.FailNow()
+	}
+}
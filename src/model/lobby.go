@@ -0,0 +1,407 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Most online players a lobby room can wait for.
+*/
+const MAX_ROOM_PLAYERS = 8
+
+/*
+Most rooms a lobby keeps open at once, unless set otherwise.
+*/
+const MAX_ROOMS = 64
+
+/*
+How long a room waits for its first player before it closes, unless set 
+otherwise.
+*/
+const ROOM_JOIN_TIMEOUT = 5 * time.Minute
+
+/*
+Returned when a room is created while the lobby has as many rooms open as 
+it allows.
+*/
+var ErrLobbyFull = errors.New("the lobby has no space for another room, try again later")
+
+/*
+A lobby server hosts many games at once, each in a named room with its own
+network. Connections start in the lobby, where clients list, create and
+enter rooms, and are handed to the rooms network once they enter one.
+*/
+type Lobby struct {
+	lock		sync.Mutex
+	rooms		map[string]*Room
+	runner		func(ctx context.Context, room *Room)
+	heartbeat	time.Duration
+	maxMissed	int
+	maxRooms	int
+	joinTimeout	time.Duration
+	logger		*log.Logger
+}
+
+/*
+A game hosted by the lobby. The room is removed from the lobby when the
+runner returns.
+*/
+type Room struct {
+	name		string
+	capacity	int
+	network		*Network
+}
+
+/*
+Sets the function that runs the game of a new room, it is started in its
+own goroutine when the room is created and the room closes when it returns.
+*/
+func (l *Lobby) SetRunner(runner func(ctx context.Context, room *Room)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.runner = runner
+}
+
+/*
+Sets the heartbeat used for the players of every room, see
+Network.SetHeartbeat.
+*/
+func (l *Lobby) SetHeartbeat(interval time.Duration, maxMissed int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.heartbeat = interval
+	l.maxMissed = maxMissed
+}
+
+/*
+Sets how many rooms can be open at once and how long a new room waits for 
+its first player before it closes. Zero keeps MAX_ROOMS and 
+ROOM_JOIN_TIMEOUT.
+*/
+func (l *Lobby) SetRoomLimits(rooms int, joinTimeout time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.maxRooms = rooms
+	l.joinTimeout = joinTimeout
+}
+
+/*
+Sets where the lobby logs rooms opening and closing, nothing is logged
+without a logger.
+*/
+func (l *Lobby) SetLogger(logger *log.Logger) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.logger = logger
+}
+
+/*
+Logs a line if a logger is set.
+*/
+func (l *Lobby) logf(format string, args ...interface{}) {
+	l.lock.Lock()
+	logger := l.logger
+	l.lock.Unlock()
+	if logger != nil {
+		logger.Printf(format, args...)
+	}
+}
+
+/*
+Accepts connections from an already open listener until the context is
+done, rooms that are still running are closed along with it.
+
+Returns nil once the context is done, or the error that closed the listener.
+*/
+func (l *Lobby) Serve(ctx context.Context, listen net.Listener) error {
+	return acceptLoop(ctx, listen, func(conn net.Conn) {
+		l.handleConnection(ctx, conn)
+	}, func(err error) {
+		l.logf("could not accept connection %v", err)
+	})
+}
+
+/*
+Answers lobby messages on a new connection until the client enters a room,
+the connection is then handed to the join handshake of that room along with
+what was agreed on in the hello.
+*/
+func (l *Lobby) handleConnection(ctx context.Context, conn net.Conn) {
+	reader, writer := NewMessageReader(conn), NewMessageWriter(conn)
+	player := newPlayerConnection(conn, reader, writer)
+	for {
+		conn.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+		msg, err := reader.Receive()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		switch msg.Type {
+		case MSG_HELLO:
+			helloErr := answerHello(player, msg)
+			if helloErr != nil {
+				l.logf("refused %s, %v", addressIP(conn.RemoteAddr()), helloErr)
+				conn.Close()
+				return
+			}
+
+		case MSG_LIST_ROOMS:
+			writer.Send(MSG_ROOMS, msg.ID, RoomsPayload{Rooms: l.ListRooms()})
+
+		case MSG_CREATE_ROOM:
+			var create CreateRoomPayload
+			decodeErr := msg.Decode(&create)
+			if decodeErr != nil {
+				writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Reason: "invalid create room message"})
+				continue
+			}
+			room, createErr := l.CreateRoom(ctx, create.Name, create.Players)
+			if createErr != nil {
+				writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Reason: createErr.Error()})
+				continue
+			}
+			writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Accepted: true, Name: room.name})
+			room.network.handshake(player)
+			return
+
+		case MSG_ENTER_ROOM:
+			var enter EnterRoomPayload
+			decodeErr := msg.Decode(&enter)
+			if decodeErr != nil {
+				writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Reason: "invalid enter room message"})
+				continue
+			}
+			room, findErr := l.findRoom(enter.Name)
+			if findErr != nil {
+				writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Reason: findErr.Error()})
+				continue
+			}
+			writer.Send(MSG_ROOM_RESULT, msg.ID, RoomResultPayload{Accepted: true, Name: room.name})
+			room.network.handshake(player)
+			return
+
+		case MSG_ADMIN:
+			writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: "remote admin is not available on a lobby server"})
+		}
+	}
+}
+
+/*
+Opens a new room that waits for the given number of online players, and
+starts the runner for it.
+
+Returns an error if the name is invalid or taken, the number of players is
+out of range, or ErrLobbyFull if no more rooms can be opened.
+*/
+func (l *Lobby) CreateRoom(ctx context.Context, name string, players int) (*Room, error) {
+	nameErr := ValidatePlayerName(name)
+	if nameErr != nil {
+		return nil, errors.New("room " + nameErr.Error())
+	}
+	if players < 1 || players > MAX_ROOM_PLAYERS {
+		return nil, errors.New("a room needs between 1 and " + strconv.Itoa(MAX_ROOM_PLAYERS) + " players")
+	}
+
+	l.lock.Lock()
+	if l.rooms == nil {
+		l.rooms = make(map[string]*Room)
+	}
+	if l.rooms[name] != nil {
+		l.lock.Unlock()
+		return nil, errors.New("room name is taken")
+	}
+	maxRooms := l.maxRooms
+	if maxRooms <= 0 {
+		maxRooms = MAX_ROOMS
+	}
+	if len(l.rooms) >= maxRooms {
+		l.lock.Unlock()
+		return nil, ErrLobbyFull
+	}
+	room := &Room{
+		name: name,
+		capacity: players,
+		network: new(Network),
+	}
+	room.network.SetCapacity(players)
+	room.network.SetHeartbeat(l.heartbeat, l.maxMissed)
+	l.rooms[name] = room
+	runner := l.runner
+	joinTimeout := l.joinTimeout
+	if joinTimeout <= 0 {
+		joinTimeout = ROOM_JOIN_TIMEOUT
+	}
+	l.lock.Unlock()
+
+	l.logf("room %s opened for %d players", name, players)
+	go l.runRoom(ctx, room, runner, joinTimeout)
+	return room, nil
+}
+
+/*
+Runs the game of a room and removes the room once it is over. A room that 
+nobody joined within joinTimeout is closed.
+*/
+func (l *Lobby) runRoom(ctx context.Context, room *Room, runner func(ctx context.Context, room *Room), joinTimeout time.Duration) {
+	roomCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go room.network.heartbeatLoop(roomCtx)
+	abandoned := time.AfterFunc(joinTimeout, func() {
+		if room.network.CountOnlinePlayers() == 0 {
+			l.logf("room %s was not joined in time", room.name)
+			stop()
+		}
+	})
+	defer abandoned.Stop()
+	if runner != nil {
+		runner(roomCtx, room)
+	} else {
+		<-roomCtx.Done()
+	}
+
+	l.lock.Lock()
+	delete(l.rooms, room.name)
+	l.lock.Unlock()
+	room.network.CloseConnections()
+	l.logf("room %s closed", room.name)
+}
+
+/*
+Returns the room with the given name. Whether the player gets a seat is 
+decided by the join handshake of the room, so that players can resume 
+their seat in a game that has started.
+
+Returns an error if there is no such room.
+*/
+func (l *Lobby) findRoom(name string) (*Room, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	room := l.rooms[name]
+	if room == nil {
+		return nil, errors.New("no room named " + name)
+	}
+	return room, nil
+}
+
+/*
+Returns the open rooms sorted by name.
+*/
+func (l *Lobby) ListRooms() []RoomInfo {
+	l.lock.Lock()
+	rooms := make([]*Room, 0, len(l.rooms))
+	for _, room := range l.rooms {
+		rooms = append(rooms, room)
+	}
+	l.lock.Unlock()
+
+	infos := make([]RoomInfo, len(rooms))
+	for i := 0; i < len(rooms); i++ {
+		infos[i] = rooms[i].Info()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+/*
+Returns the name of the room.
+*/
+func (r *Room) Name() string {
+	return r.name
+}
+
+/*
+Returns how many online players the room waits for.
+*/
+func (r *Room) Capacity() int {
+	return r.capacity
+}
+
+/*
+Returns the network of the players in the room.
+*/
+func (r *Room) Network() *Network {
+	return r.network
+}
+
+/*
+Returns a summary of the room for the room list.
+*/
+func (r *Room) Info() RoomInfo {
+	return RoomInfo{
+		Name: r.name,
+		Players: r.network.CountOnlinePlayers(),
+		Capacity: r.capacity,
+		Started: r.network.Started(),
+	}
+}
+
+/*
+Asks a lobby server for its open rooms.
+*/
+func (n *Network) ListRooms() ([]RoomInfo, error) {
+	var rooms RoomsPayload
+	err := n.request(MSG_LIST_ROOMS, nil, MSG_ROOMS, &rooms)
+	return rooms.Rooms, err
+}
+
+/*
+Asks a lobby server to open a room for the given number of online players
+and enters it, the join handshake follows as with any host.
+*/
+func (n *Network) CreateRoom(name string, players int) (RoomResultPayload, error) {
+	var result RoomResultPayload
+	err := n.request(MSG_CREATE_ROOM, CreateRoomPayload{Name: name, Players: players}, MSG_ROOM_RESULT, &result)
+	if err == nil && result.Accepted {
+		n.setRoom(result.Name)
+	}
+	return result, err
+}
+
+/*
+Asks a lobby server to enter a room, the join handshake follows as with
+any host.
+*/
+func (n *Network) EnterRoom(name string) (RoomResultPayload, error) {
+	var result RoomResultPayload
+	err := n.request(MSG_ENTER_ROOM, EnterRoomPayload{Name: name}, MSG_ROOM_RESULT, &result)
+	if err == nil && result.Accepted {
+		n.setRoom(result.Name)
+	}
+	return result, err
+}
+
+/*
+Remembers the room the client is in, so that a reconnect enters it again.
+*/
+func (n *Network) setRoom(name string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.room = name
+}
+
+/*
+Tells the host that the player leaves the game and closes the connection.
+The room and resume token are forgotten.
+*/
+func (n *Network) Leave() error {
+	n.lock.Lock()
+	writer, host := n.hostWriter, n.host
+	n.room = ""
+	n.token = ""
+	n.lock.Unlock()
+	if writer == nil {
+		return errors.New("not connected to a host")
+	}
+	sendErr := writer.Send(MSG_LEAVE, 0, nil)
+	host.Close()
+	return sendErr
+}
@@ -0,0 +1,185 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"testing"
+	"time"
+)
+
+/*
+Starts a lobby on a random loopback port, every room runs the given runner.
+*/
+func generateTestLobby(t *testing.T, runner func(ctx context.Context, room *model.Room)) (*model.Lobby, string) {
+	listen, listenErr := model.NewTCPTransport().Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("incorrect test config,", listenErr)
+		t.FailNow()
+	}
+	lobby := new(model.Lobby)
+	lobby.SetRunner(runner)
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	go lobby.Serve(ctx, listen)
+	return lobby, listen.Addr().String()
+}
+
+/*
+Connects a client to the lobby.
+*/
+func dialTestLobby(t *testing.T, address string) *model.Network {
+	client := new(model.Network)
+	dialErr := client.DialHost(address)
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	return client
+}
+
+func TestLobbyRooms(t *testing.T) {
+	started := make(chan string, 1)
+	lobby, address := generateTestLobby(t, func(ctx context.Context, room *model.Room) {
+		events := room.Network().Events()
+		for room.Network().CountOnlinePlayers() < room.Capacity() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-events:
+			}
+		}
+		room.Network().StartGame()
+		started <- room.Name()
+		<-ctx.Done()
+	})
+
+	alice := dialTestLobby(t, address)
+	rooms, listErr := alice.ListRooms()
+	if listErr != nil || len(rooms) != 0 {
+		t.Log("expected an empty lobby, received", rooms, listErr)
+		t.FailNow()
+	}
+	created, createErr := alice.CreateRoom("den", 2)
+	if createErr != nil || !created.Accepted {
+		t.Log("expected the room to be created, received", created, createErr)
+		t.FailNow()
+	}
+	accepted, reason, joinErr := alice.Join("alice")
+	if joinErr != nil || !accepted {
+		t.Log("expected to join the new room,", reason, joinErr)
+		t.FailNow()
+	}
+
+	bob := dialTestLobby(t, address)
+	rooms, _ = bob.ListRooms()
+	if len(rooms) != 1 || rooms[0].Name != "den" || rooms[0].Players != 1 || rooms[0].Capacity != 2 {
+		t.Log("expected the room to be listed with one player, received", rooms)
+		t.FailNow()
+	}
+	duplicate, _ := bob.CreateRoom("den", 3)
+	if duplicate.Accepted {
+		t.Log("expected a second room with the same name to be rejected")
+		t.FailNow()
+	}
+	entered, enterErr := bob.EnterRoom("den")
+	if enterErr != nil || !entered.Accepted {
+		t.Log("expected to enter the room, received", entered, enterErr)
+		t.FailNow()
+	}
+	accepted, reason, _ = bob.Join("alice")
+	if accepted {
+		t.Log("expected names to be unique within the room")
+		t.FailNow()
+	}
+	accepted, reason, _ = bob.Join("bob")
+	if !accepted {
+		t.Log("expected to join the room,", reason)
+		t.FailNow()
+	}
+
+	select {
+	case name := <-started:
+		if name != "den" {
+			t.Log("unexpected room started", name)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("the room did not start once it was full")
+		t.FailNow()
+	}
+	rooms = lobby.ListRooms()
+	if len(rooms) != 1 || !rooms[0].Started {
+		t.Log("expected the room to be listed as started, received", rooms)
+		t.FailNow()
+	}
+}
+
+func TestLobbyInvalidRooms(t *testing.T) {
+	_, address := generateTestLobby(t, nil)
+	client := dialTestLobby(t, address)
+
+	invalid := []model.CreateRoomPayload{
+		{Name: "", Players: 2},
+		{Name: "<script>", Players: 2},
+		{Name: "empty", Players: 0},
+		{Name: "crowded", Players: model.MAX_ROOM_PLAYERS + 1},
+	}
+	for i := 0; i < len(invalid); i++ {
+		result, err := client.CreateRoom(invalid[i].Name, invalid[i].Players)
+		if err != nil || result.Accepted {
+			t.Log("expected the room to be rejected", invalid[i], result, err)
+			t.FailNow()
+		}
+	}
+	result, err := client.EnterRoom("missing")
+	if err != nil || result.Accepted {
+		t.Log("expected entering a missing room to be rejected", result, err)
+		t.FailNow()
+	}
+}
+
+func TestLobbyRoomCloses(t *testing.T) {
+	lobby, address := generateTestLobby(t, func(ctx context.Context, room *model.Room) {
+		// the game is over as soon as someone joins
+		<-room.Network().Events()
+	})
+	client := dialTestLobby(t, address)
+	client.CreateRoom("brief", 1)
+	client.Join("player")
+
+	_, closedErr := client.Receive()
+	if closedErr == nil {
+		t.Log("expected the connection to close with the room")
+		t.FailNow()
+	}
+	if len(lobby.ListRooms()) != 0 {
+		t.Log("expected the room to be removed, received", lobby.ListRooms())
+		t.FailNow()
+	}
+}
+
+func TestLobbyRoomLimits(t *testing.T) {
+	lobby, address := generateTestLobby(t, nil)
+	lobby.SetRoomLimits(1, 100 * time.Millisecond)
+	client := dialTestLobby(t, address)
+
+	created, createErr := lobby.CreateRoom(context.Background(), "first", 2)
+	if createErr != nil {
+		t.Log("unexpected create error:", createErr)
+		t.FailNow()
+	}
+	full, fullErr := client.CreateRoom("second", 2)
+	if fullErr != nil || full.Accepted || full.Reason != model.ErrLobbyFull.Error() {
+		t.Log("expected the second room to be refused, received", full, fullErr)
+		t.FailNow()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(lobby.ListRooms()) != 0 {
+		if time.Now().After(deadline) {
+			t.Log("expected", created.Name(), "to close when nobody joined")
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
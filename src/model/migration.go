@@ -0,0 +1,398 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+/*
+How long a reconnecting player waits for the host or the backup to answer
+the resume, the backup only answers once it has taken over.
+*/
+const RESUME_TIMEOUT = 10 * time.Second
+
+/*
+Returns the state of the game for the backup, see SnapshotPayload. Only
+call this from the game loop between rounds, kicked players are left out.
+*/
+func (b *Board) Snapshot() SnapshotPayload {
+	snapshot := SnapshotPayload{
+		WinCondition: b.winCondition,
+		TurnTimeout: b.turnTimeout,
+		JudgeTimeout: b.judgeTimeout,
+		BotCover: b.botCover,
+		Round: b.round,
+	}
+	if len(b.players) > 0 {
+		snapshot.Judge = b.CurrentJudgeName()
+	}
+	for i := 0; i < len(b.players); i++ {
+		player := b.players[i]
+		if b.isKicked(player.PlayerName()) {
+			continue
+		}
+		snapshot.Players = append(snapshot.Players, SnapshotPlayer{
+			Name: player.PlayerName(),
+			Host: player.Host(),
+			Bot: player.Bot(),
+			HandCapacity: player.HandCapacity(),
+			Points: snapshotCards(player.points),
+		})
+	}
+	return snapshot
+}
+
+/*
+Sends the state of the game to the backup, see Network.Replicate. Offline
+games have nobody to replicate to.
+
+Returns an error if there is no connected player to be the backup.
+*/
+func (b *Board) Replicate() error {
+	if b.network == nil {
+		return nil
+	}
+	return b.network.Replicate(b.Snapshot())
+}
+
+/*
+Rebuilds a board from a snapshot for the player named hostName, who hosts
+the game from now on. The previous host can not come back, so their seat
+is taken over by a bot. The board has no decks and empty hands, load the
+decks and deal with DealRestored.
+
+Returns an error if hostName has no seat in the snapshot.
+*/
+func RestoreBoard(snapshot SnapshotPayload, hostName string) (*Board, error) {
+	board := new(Board)
+	found := false
+	for _, seat := range snapshot.Players {
+		host := seat.Name == hostName
+		bot := seat.Bot || (seat.Host && !host)
+		if host {
+			found = true
+		}
+		player := NewPlayer(seat.Name, host, bot, seat.HandCapacity)
+		player.points = restoreCards(seat.Points, "green apple")
+		board.players = append(board.players, *player)
+	}
+	if !found {
+		return nil, errors.New("the snapshot has no seat for " + hostName)
+	}
+	for i := 0; i < len(board.players); i++ {
+		if board.players[i].PlayerName() == snapshot.Judge {
+			board.judge = i
+		}
+	}
+	board.winCondition = snapshot.WinCondition
+	board.round = snapshot.Round
+	board.SetTimers(snapshot.TurnTimeout, snapshot.JudgeTimeout)
+	board.SetBotCover(snapshot.BotCover)
+	return board, nil
+}
+
+/*
+Deals a restored board from freshly loaded decks. The green apples that
+were already won are taken out of the deck, then both decks are shuffled
+and every hand is filled.
+
+Returns an error if the decks run out of cards.
+*/
+func (b *Board) DealRestored() error {
+	won := make(map[int]bool)
+	for i := 0; i < len(b.players); i++ {
+		for _, card := range b.players[i].points {
+			won[card.id] = true
+		}
+	}
+	var left []Card
+	for _, card := range b.greenApples.deck {
+		if !won[card.id] {
+			left = append(left, card)
+		}
+	}
+	b.greenApples.deck = left
+	shuffleGreenErr := b.ShuffleGreenApples()
+	if shuffleGreenErr != nil {
+		return shuffleGreenErr
+	}
+	shuffleRedErr := b.ShuffleRedApples()
+	if shuffleRedErr != nil {
+		return shuffleRedErr
+	}
+	return b.FillHands()
+}
+
+func snapshotCards(cards []Card) []SnapshotCard {
+	snapshot := make([]SnapshotCard, len(cards))
+	for i := 0; i < len(cards); i++ {
+		snapshot[i] = SnapshotCard{ID: cards[i].id, Header: cards[i].header, Description: cards[i].description}
+	}
+	return snapshot
+}
+
+func restoreCards(snapshot []SnapshotCard, cardType string) []Card {
+	cards := make([]Card, len(snapshot))
+	for i := 0; i < len(snapshot); i++ {
+		cards[i] = MintCard(cardType, snapshot[i].Header, snapshot[i].Description)
+		cards[i].id = snapshot[i].ID
+	}
+	return cards
+}
+
+/*
+Hashes a resume token for the snapshot.
+*/
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Returns the hash a player resumes their seat with, seats restored from a
+snapshot only know the hash until the player is back.
+*/
+func (p *PlayerConnection) resumeHash() string {
+	if p.token != "" {
+		return hashToken(p.token)
+	}
+	return p.tokenHash
+}
+
+/*
+Sends the snapshot to the backup, along with the hashed resume tokens and
+the heartbeat of the game. The first connected player becomes the backup
+and stays it for as long as they are connected.
+
+Returns an error if there is no connected player to be the backup or the
+snapshot could not be sent.
+*/
+func (n *Network) Replicate(snapshot SnapshotPayload) error {
+	n.lock.Lock()
+	index := n.indexOf(n.backup)
+	if index < 0 || !n.players[index].connected {
+		index = -1
+		for i := 0; i < len(n.players); i++ {
+			if n.players[i].connected {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		n.lock.Unlock()
+		return errors.New("no connected player to be the backup")
+	}
+	backup := n.players[index]
+	if backup.playerName != n.backup {
+		n.backup = backup.playerName
+		n.backupAddress = ""
+	}
+	for i := 0; i < len(snapshot.Players); i++ {
+		seat := n.indexOf(snapshot.Players[i].Name)
+		if seat >= 0 {
+			snapshot.Players[i].TokenHash = n.players[seat].resumeHash()
+		}
+	}
+	snapshot.Heartbeat = n.heartbeat
+	snapshot.MaxMissed = n.maxMissed
+	n.lock.Unlock()
+	return backup.writer.Send(MSG_SNAPSHOT, 0, snapshot)
+}
+
+/*
+Handles the backup telling where it will listen once it takes over. Every
+other player is told the address when it changes, the backup is left out
+since it can not reconnect to itself.
+*/
+func (n *Network) backupReady(player *PlayerConnection, msg Message) {
+	var ready BackupReadyPayload
+	decodeErr := msg.Decode(&ready)
+	if decodeErr != nil || ready.Port <= 0 {
+		return
+	}
+	address := net.JoinHostPort(addressIP(player.conn.RemoteAddr()), strconv.Itoa(ready.Port))
+	n.lock.Lock()
+	if player.playerName != n.backup || (address == n.backupAddress && ready.Fingerprint == n.backupFingerprint) {
+		n.lock.Unlock()
+		return
+	}
+	n.backupAddress = address
+	n.backupFingerprint = ready.Fingerprint
+	var others []*PlayerConnection
+	for i := 0; i < len(n.players); i++ {
+		if n.players[i].connected && n.players[i] != player {
+			others = append(others, n.players[i])
+		}
+	}
+	n.lock.Unlock()
+
+	for i := 0; i < len(others); i++ {
+		others[i].writer.Send(MSG_BACKUP, 0, BackupPayload{Address: address, Fingerprint: ready.Fingerprint})
+	}
+	n.emit(NetworkEvent{Type: EVENT_BACKUP_READY, PlayerName: player.playerName, Text: address})
+}
+
+/*
+Keeps the snapshot received from the host, making this client the backup.
+The first snapshot opens the listener the backup takes over on, with a
+fresh self-signed certificate if the game uses TLS. Every snapshot is
+answered with the port, so the host always knows where the backup is.
+
+Returns an error if the snapshot is invalid or no listener could be opened.
+*/
+func (n *Network) StoreSnapshot(msg Message) error {
+	var snapshot SnapshotPayload
+	decodeErr := msg.Decode(&snapshot)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	n.lock.Lock()
+	n.snapshot = &snapshot
+	listen, fingerprint := n.backupListener, n.backupFingerprint
+	n.lock.Unlock()
+
+	if listen == nil {
+		var listenErr error
+		listen, fingerprint, listenErr = n.listenForTakeover()
+		if listenErr != nil {
+			return listenErr
+		}
+		n.lock.Lock()
+		n.backupListener = listen
+		n.backupFingerprint = fingerprint
+		n.lock.Unlock()
+	}
+	_, portText, _ := net.SplitHostPort(listen.Addr().String())
+	port, _ := strconv.Atoi(portText)
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	return writer.Send(MSG_BACKUP_READY, 0, BackupReadyPayload{Port: port, Fingerprint: fingerprint})
+}
+
+/*
+Opens the listener the backup serves on after taking over, on a free port
+of the interface this client reaches the host through, which the other
+players reach the host through as well. Connections wait in the backlog
+until then.
+
+Returns the listener and the certificate fingerprint for TLS games.
+*/
+func (n *Network) listenForTakeover() (net.Listener, string, error) {
+	n.lock.Lock()
+	host := n.host
+	n.lock.Unlock()
+	if host == nil {
+		return nil, "", errors.New("not connected to a host")
+	}
+	transport := n.Transport()
+	fingerprint := ""
+	if config := transport.TLS(); config != nil {
+		cert, certErr := GenerateCertificate()
+		if certErr != nil {
+			return nil, "", certErr
+		}
+		// the same settings keep dialing the host while serving the certificate
+		config = config.Clone()
+		config.Certificates = []tls.Certificate{cert}
+		transport = transport.WithTLS(config)
+		n.SetTransport(transport)
+		fingerprint = Fingerprint(cert)
+	}
+	listen, listenErr := transport.Listen(net.JoinHostPort(addressIP(host.LocalAddr()), "0"))
+	if listenErr != nil {
+		return nil, "", listenErr
+	}
+	return listen, fingerprint, nil
+}
+
+/*
+Keeps the address of the backup received from the host. A client that was
+the backup before is not anymore, its snapshot is dropped and its listener
+closed.
+
+Returns an error if the message is invalid.
+*/
+func (n *Network) StoreBackup(msg Message) error {
+	var backup BackupPayload
+	decodeErr := msg.Decode(&backup)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	n.lock.Lock()
+	listen := n.backupListener
+	n.backupListener = nil
+	n.snapshot = nil
+	n.backupAddress = backup.Address
+	n.backupFingerprint = backup.Fingerprint
+	n.lock.Unlock()
+	if listen != nil {
+		listen.Close()
+	}
+	return nil
+}
+
+/*
+Returns true if this client holds a snapshot and can take over hosting.
+*/
+func (n *Network) IsBackup() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.snapshot != nil && n.backupListener != nil
+}
+
+/*
+Takes over hosting after the host went away. The returned network serves
+on the listener opened for the takeover until the context is done, with
+the heartbeat of the host and the password this client joined with, and
+the seats of the other online players wait for them to resume with their
+tokens. The caller rebuilds the board from the snapshot, see RestoreBoard.
+
+Returns an error if this client is not the backup.
+*/
+func (n *Network) Promote(ctx context.Context) (*Network, SnapshotPayload, error) {
+	n.lock.Lock()
+	snapshot, listen, name, password := n.snapshot, n.backupListener, n.playerName, n.password
+	n.snapshot = nil
+	n.backupListener = nil
+	n.lock.Unlock()
+	if snapshot == nil || listen == nil {
+		return nil, SnapshotPayload{}, errors.New("this client is not the backup")
+	}
+
+	host := new(Network)
+	host.SetTransport(n.Transport())
+	host.SetHeartbeat(snapshot.Heartbeat, snapshot.MaxMissed)
+	host.SetPassword(password)
+	for _, seat := range snapshot.Players {
+		if seat.Host || seat.Bot || seat.Name == name {
+			host.ReserveName(seat.Name)
+			continue
+		}
+		host.players = append(host.players, restoredSeat(seat))
+	}
+	host.StartGame()
+	go host.Serve(ctx, listen)
+	return host, *snapshot, nil
+}
+
+/*
+Creates the seat of an online player from a snapshot. A closed connection
+stands in until the player resumes.
+*/
+func restoredSeat(seat SnapshotPlayer) *PlayerConnection {
+	placeholder, other := net.Pipe()
+	placeholder.Close()
+	other.Close()
+	player := newPlayerConnection(placeholder, NewMessageReader(placeholder), NewMessageWriter(placeholder))
+	player.playerName = seat.Name
+	player.tokenHash = seat.TokenHash
+	return player
+}
@@ -0,0 +1,232 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+Creates a board with a host player, the given online players and bots up
+to four seats, with full hands and the win condition set.
+*/
+func generateMigrationBoard(t *testing.T, online ...string) *model.Board {
+	players := []model.Player{*model.NewPlayer("host player", true, false, 7)}
+	for _, name := range online {
+		players = append(players, *model.NewPlayer(name, false, false, 7))
+	}
+	for i := 0; len(players) < 4; i++ {
+		players = append(players, *model.NewPlayer("bot "+string(rune('a'+i)), false, true, 7))
+	}
+	return newTestBoard(t, players...)
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	board := generateMigrationBoard(t, "alice")
+	board.DrawGreenApple()
+	greenApple, _ := board.PickUpGreenApple()
+	board.AwardScore("alice", greenApple)
+	board.ItterateJudge()
+	board.SetTimers(time.Minute, 2*time.Minute)
+
+	// the snapshot travels as a message
+	msg, encodeErr := model.NewMessage(model.MSG_SNAPSHOT, 0, board.Snapshot())
+	if encodeErr != nil {
+		t.Log("unexpected encode error:", encodeErr)
+		t.FailNow()
+	}
+	var snapshot model.SnapshotPayload
+	decodeErr := msg.Decode(&snapshot)
+	if decodeErr != nil {
+		t.Log("unexpected decode error:", decodeErr)
+		t.FailNow()
+	}
+
+	restored, restoreErr := model.RestoreBoard(snapshot, "alice")
+	if restoreErr != nil {
+		t.Log("unexpected restore error:", restoreErr)
+		t.FailNow()
+	}
+	if restored.CurrentJudgeName() != board.CurrentJudgeName() || restored.GetWinCondition() != board.GetWinCondition() {
+		t.Log("expected the judge and win condition to be restored")
+		t.FailNow()
+	}
+	for _, name := range []string{"host player", "alice", "bot a", "bot b"} {
+		restoredHand, _ := restored.PlayersHand(name)
+		if len(restoredHand) != 0 {
+			t.Log("expected the hand of", name, "to stay with the host")
+			t.FailNow()
+		}
+	}
+	leader, _ := restored.Leader()
+	if leader.PlayerName() != "alice" || leader.Score() != 1 {
+		t.Log("expected the score to be restored, leader is", leader.PlayerName())
+		t.FailNow()
+	}
+
+	// the backup deals from its own decks, without the green apple that was won
+	redPath, _ := filepath.Abs("../resources/testSetRA.txt")
+	greenPath, _ := filepath.Abs("../resources/testSetGA.txt")
+	restored.LoadRedApples(redPath)
+	restored.LoadGreenApples(greenPath)
+	dealErr := restored.DealRestored()
+	if dealErr != nil || !restored.AllHandsFull() {
+		t.Log("expected new hands to be dealt:", dealErr)
+		t.FailNow()
+	}
+	for restored.DrawGreenApple() == nil {
+		card, _ := restored.PickUpGreenApple()
+		if card.ID() == greenApple.ID() {
+			t.Log("expected the won green apple to be left out of the deck")
+			t.FailNow()
+		}
+	}
+
+	again := restored.Snapshot()
+	if again.TurnTimeout != time.Minute || again.JudgeTimeout != 2*time.Minute {
+		t.Log("expected the timers to be restored")
+		t.FailNow()
+	}
+	for _, seat := range again.Players {
+		if seat.Name == "alice" && (!seat.Host || seat.Bot) {
+			t.Log("expected alice to host the restored game")
+			t.FailNow()
+		}
+		if seat.Name == "host player" && (seat.Host || !seat.Bot) {
+			t.Log("expected a bot to take over the seat of the previous host")
+			t.FailNow()
+		}
+	}
+
+	_, missingErr := model.RestoreBoard(snapshot, "nobody")
+	if missingErr == nil {
+		t.Log("expected restoring for a player without a seat to fail")
+		t.FailNow()
+	}
+}
+
+/*
+Reads messages on a client until one of the given type arrives.
+*/
+func receiveClientUntil(t *testing.T, client *model.Network, msgType string) model.Message {
+	for {
+		msg, err := client.Receive()
+		if err != nil {
+			t.Log("unexpected receive error waiting for", msgType, err)
+			t.FailNow()
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestHostMigration(t *testing.T) {
+	host := new(model.Network)
+	host.SetPassword("secret")
+	host.SetHeartbeat(time.Minute, 2)
+	hostCtx, stopHost := context.WithCancel(context.Background())
+	defer stopHost()
+	address, listenErr := host.Listener(hostCtx, "127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+
+	clients := make(map[string]*model.Network)
+	for _, name := range []string{"alice", "bob"} {
+		clients[name] = new(model.Network)
+		clients[name].SetPassword("secret")
+		dialErr := clients[name].DialHost(address.String())
+		if dialErr != nil {
+			t.Log("unexpected dial error:", dialErr)
+			t.FailNow()
+		}
+		accepted, reason, joinErr := clients[name].Join(name)
+		if joinErr != nil || !accepted {
+			t.Log("expected the join to be accepted:", reason, joinErr)
+			t.FailNow()
+		}
+		awaitEvent(t, host)
+	}
+	host.StartGame()
+	alice, bob := clients["alice"], clients["bob"]
+
+	board := generateMigrationBoard(t, "alice", "bob")
+	board.SetNetwork(host)
+	replicateErr := board.Replicate()
+	if replicateErr != nil {
+		t.Log("unexpected replicate error:", replicateErr)
+		t.FailNow()
+	}
+
+	// the first player to join is the backup
+	storeErr := alice.StoreSnapshot(receiveClientUntil(t, alice, model.MSG_SNAPSHOT))
+	if storeErr != nil || !alice.IsBackup() {
+		t.Log("expected alice to become the backup:", storeErr)
+		t.FailNow()
+	}
+	event := awaitEvent(t, host)
+	if event.Type != model.EVENT_BACKUP_READY || event.PlayerName != "alice" {
+		t.Log("expected the backup to be ready, received", event)
+		t.FailNow()
+	}
+	bob.StoreBackup(receiveClientUntil(t, bob, model.MSG_BACKUP))
+	if bob.IsBackup() {
+		t.Log("expected bob not to be the backup")
+		t.FailNow()
+	}
+
+	// the host goes away for good
+	stopHost()
+	host.CloseConnections()
+
+	promoteCtx, stopPromoted := context.WithCancel(context.Background())
+	defer stopPromoted()
+	promoted, snapshot, promoteErr := alice.Promote(promoteCtx)
+	if promoteErr != nil {
+		t.Log("unexpected promote error:", promoteErr)
+		t.FailNow()
+	}
+	if snapshot.Heartbeat != time.Minute || snapshot.MaxMissed != 2 {
+		t.Log("expected the heartbeat to be replicated, received", snapshot.Heartbeat, snapshot.MaxMissed)
+		t.FailNow()
+	}
+	restored, restoreErr := model.RestoreBoard(snapshot, "alice")
+	if restoreErr != nil {
+		t.Log("unexpected restore error:", restoreErr)
+		t.FailNow()
+	}
+	if restored.CountPlayers() != 4 {
+		t.Log("expected every seat to survive the migration")
+		t.FailNow()
+	}
+	if promoted.IsConnected("bob") {
+		t.Log("expected the seat of bob to wait for a resume")
+		t.FailNow()
+	}
+
+	reconnectErr := bob.Reconnect(3, 10*time.Millisecond)
+	if reconnectErr != nil {
+		t.Log("expected bob to resume on the backup:", reconnectErr)
+		t.FailNow()
+	}
+	event = awaitEvent(t, promoted)
+	if event.Type != model.EVENT_PLAYER_RESUMED || event.PlayerName != "bob" || !promoted.IsConnected("bob") {
+		t.Log("expected bob to resume the seat, received", event)
+		t.FailNow()
+	}
+
+	// once resumed the player can resume again with the same token
+	bob.Reconnect(1, 0)
+	event = awaitEvent(t, promoted)
+	if event.Type == model.EVENT_PLAYER_LEFT {
+		event = awaitEvent(t, promoted)
+	}
+	if event.Type != model.EVENT_PLAYER_RESUMED {
+		t.Log("expected the token to keep working after the migration, received", event)
+		t.FailNow()
+	}
+}
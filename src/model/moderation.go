@@ -0,0 +1,336 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+/*
+Returned by the round steps once the host has ended the game early, the
+game loop should then declare the leader the winner.
+*/
+var ErrGameEnded = errors.New("the game was ended by the host")
+
+/*
+Commands accepted by Moderate.
+*/
+const MODERATION_COMMANDS = "kick NAME, ban NAME or ADDRESS, pause, resume, skip, end"
+
+/*
+Runs a moderation command typed by the host or sent by a remote admin. The
+board is moderated from other goroutines than the game loop, so every
+moderation method only records what to do under the lock and the game
+loop applies it at the next safe point.
+
+Returns an error if the command is unknown or can not be run right now.
+*/
+func (b *Board) Moderate(command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return errors.New("no command given, use " + MODERATION_COMMANDS)
+	}
+	argument := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(command), fields[0]))
+	switch fields[0] {
+	case "kick":
+		return b.Kick(argument)
+	case "ban":
+		return b.Ban(argument)
+	case "pause":
+		return b.Pause()
+	case "resume":
+		return b.Resume()
+	case "skip":
+		return b.SkipJudge()
+	case "end":
+		return b.End()
+	}
+	return errors.New("unknown command " + fields[0] + ", use " + MODERATION_COMMANDS)
+}
+
+/*
+Removes an online player from the game. The connection is closed at once,
+the player leaves the board at the start of the next round and their hand
+goes to the red apple discard pile.
+
+Returns an error if there is no online player with that name.
+*/
+func (b *Board) Kick(name string) error {
+	network, err := b.onlineNetwork()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("name the player to kick")
+	}
+	// marked before the connection closes, so the prompt failing is put down to the kick
+	b.lock.Lock()
+	b.kicked = append(b.kicked, name)
+	b.lock.Unlock()
+	kickErr := network.Kick(name, "You were kicked from the game by the host.")
+	if kickErr != nil {
+		b.unmarkKicked(name)
+		return kickErr
+	}
+	b.announce(name + " was kicked from the game by the host.")
+	return nil
+}
+
+func (b *Board) unmarkKicked(name string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i := len(b.kicked) - 1; i >= 0; i-- {
+		if b.kicked[i] == name {
+			b.kicked = append(b.kicked[:i], b.kicked[i+1:]...)
+			return
+		}
+	}
+}
+
+/*
+Bans an address for the rest of the session, given as an IP or as the name
+of a player or spectator connected from it. Players from the address are
+kicked.
+
+Returns an error if the target is neither a known name nor an IP.
+*/
+func (b *Board) Ban(target string) error {
+	network, err := b.onlineNetwork()
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return errors.New("name the player or address to ban")
+	}
+	players, banErr := network.Ban(target)
+	if banErr != nil {
+		return banErr
+	}
+	b.announce("The host banned " + target + " from the game.")
+	for i := 0; i < len(players); i++ {
+		b.Kick(players[i])
+	}
+	return nil
+}
+
+/*
+Pauses the game before the next prompt, prompts that are already shown
+keep running.
+
+Returns an error if the game is already paused or has ended.
+*/
+func (b *Board) Pause() error {
+	b.lock.Lock()
+	if b.ended {
+		b.lock.Unlock()
+		return ErrGameEnded
+	}
+	if b.paused != nil {
+		b.lock.Unlock()
+		return errors.New("the game is already paused")
+	}
+	b.paused = make(chan struct{})
+	b.lock.Unlock()
+	b.announce("The host paused the game.")
+	return nil
+}
+
+/*
+Resumes a paused game.
+
+Returns an error if the game is not paused.
+*/
+func (b *Board) Resume() error {
+	b.lock.Lock()
+	if b.paused == nil {
+		b.lock.Unlock()
+		return errors.New("the game is not paused")
+	}
+	close(b.paused)
+	b.paused = nil
+	b.lock.Unlock()
+	b.announce("The host resumed the game.")
+	return nil
+}
+
+/*
+Stops waiting for the current judge, the fallback judge picks the winner
+instead.
+
+Returns an error if no judge is being waited for.
+*/
+func (b *Board) SkipJudge() error {
+	b.lock.Lock()
+	cancel := b.cancelJudge
+	if cancel == nil {
+		b.lock.Unlock()
+		return errors.New("nobody is judging right now")
+	}
+	b.judgeSkipped = true
+	b.lock.Unlock()
+	cancel()
+	return nil
+}
+
+/*
+Ends the game early, the prompts that are shown are cancelled and the round
+is abandoned. The player with the highest score wins.
+
+Returns an error if the game has already been ended.
+*/
+func (b *Board) End() error {
+	b.lock.Lock()
+	if b.ended {
+		b.lock.Unlock()
+		return errors.New("the game is already ending")
+	}
+	b.ended = true
+	if b.paused != nil {
+		close(b.paused)
+		b.paused = nil
+	}
+	cancelRound, cancelJudge := b.cancelRound, b.cancelJudge
+	b.lock.Unlock()
+	if cancelRound != nil {
+		cancelRound()
+	}
+	if cancelJudge != nil {
+		cancelJudge()
+	}
+	b.announce("The host ended the game early.")
+	return nil
+}
+
+/*
+Returns true once the host has ended the game early.
+*/
+func (b *Board) Ended() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.ended
+}
+
+/*
+Returns the player with the highest score, the first of them on a tie.
+
+Returns an error if there are no players.
+*/
+func (b *Board) Leader() (Player, error) {
+	if len(b.players) == 0 {
+		return *new(Player), errors.New("no players on board")
+	}
+	leader := 0
+	for i := 1; i < len(b.players); i++ {
+		if b.players[i].Score() > b.players[leader].Score() {
+			leader = i
+		}
+	}
+	return b.players[leader], nil
+}
+
+/*
+Blocks while the game is paused.
+
+Returns ErrGameEnded if the host ended the game.
+*/
+func (b *Board) waitWhilePaused() error {
+	b.lock.Lock()
+	paused := b.paused
+	b.lock.Unlock()
+	if paused != nil {
+		<-paused
+	}
+	if b.Ended() {
+		return ErrGameEnded
+	}
+	return nil
+}
+
+/*
+Removes the kicked players from the board and puts their hands on the red
+apple discard pile. The judge stays with the same player, or passes on to
+the next one if the judge was kicked. Only call this between rounds, the
+played cards point into the players.
+*/
+func (b *Board) applyKicks() {
+	b.lock.Lock()
+	kicked := b.kicked
+	b.kicked = nil
+	b.lock.Unlock()
+	for _, name := range kicked {
+		index := -1
+		for i := 0; i < len(b.players); i++ {
+			if b.players[i].PlayerName() == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		for _, card := range b.players[index].PlayerHand() {
+			b.redApples.DiscardCard(card)
+		}
+		b.players = append(b.players[:index], b.players[index+1:]...)
+		if index < b.judge {
+			b.judge--
+		}
+		if b.judge >= len(b.players) {
+			b.judge = 0
+		}
+	}
+}
+
+/*
+Returns true if the player was kicked and has not left the board yet.
+*/
+func (b *Board) isKicked(name string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, kicked := range b.kicked {
+		if kicked == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Remembers how to cancel the card prompts of the current round, so that
+ending the game does not wait for them. Nil clears it.
+*/
+func (b *Board) setRoundCancel(cancel context.CancelFunc) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.cancelRound = cancel
+}
+
+/*
+Remembers how to cancel the current judge prompt, so that the host can skip
+the judge. Nil clears it.
+*/
+func (b *Board) setJudgeCancel(cancel context.CancelFunc) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.cancelJudge = cancel
+	b.judgeSkipped = false
+}
+
+/*
+Picks the winner for a judge that did not decide and lets everyone know
+why, unless the host ended the game.
+
+Returns ErrGameEnded if the host ended the game.
+*/
+func (b *Board) judgeInterrupted(redApples []string, reason string) (int, error) {
+	b.lock.Lock()
+	ended, skipped := b.ended, b.judgeSkipped
+	b.lock.Unlock()
+	if ended {
+		return 0, ErrGameEnded
+	}
+	if skipped {
+		reason = " was skipped by the host"
+	}
+	return b.judgeFallback(redApples, reason), nil
+}
@@ -0,0 +1,220 @@
+package model_test
+
+import (
+	"bytes"
+	"log"
+	"main/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Reads messages until one of the given type arrives.
+*/
+func receiveUntil(t *testing.T, reader *model.MessageReader, msgType string) model.Message {
+	for {
+		msg, err := reader.Receive()
+		if err != nil {
+			t.Log("expected a", msgType, "message, received", err)
+			t.FailNow()
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+/*
+Skips messages until a play message arrives, for use in goroutines where the 
+test can not be failed.
+*/
+func skipToPlay(reader *model.MessageReader) (model.Message, error) {
+	for {
+		msg, err := reader.Receive()
+		if err != nil || msg.Type == model.MSG_PLAY {
+			return msg, err
+		}
+	}
+}
+
+func TestKick(t *testing.T) {
+	board, reader, _ := generateOnlineTestBoard(t)
+	if board.Moderate("kick nobody") == nil {
+		t.Log("expected kicking an unknown player to fail")
+		t.FailNow()
+	}
+	kickErr := board.Moderate("kick online player 0")
+	if kickErr != nil {
+		t.Log("unexpected kick error:", kickErr)
+		t.FailNow()
+	}
+	var end model.EndPayload
+	receiveUntil(t, reader, model.MSG_END).Decode(&end)
+	if end.Reason == "" {
+		t.Log("expected the kicked player to be told why")
+		t.FailNow()
+	}
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.CountPlayers() != 3 || board.PlayedCards.PlayerCount() != 2 {
+		t.Log("expected the kicked player to leave the board before the round", board.CountPlayers(), board.PlayedCards.PlayerCount())
+		t.FailNow()
+	}
+	_, handErr := board.PlayersHand("online player 0")
+	if handErr == nil {
+		t.Log("expected the kicked player to be gone")
+		t.FailNow()
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	board, reader, writer := generateOnlineTestBoard(t)
+	go func() {
+		msg, err := skipToPlay(reader)
+		if err == nil {
+			writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "0"})
+		}
+	}()
+
+	pauseErr := board.Moderate("pause")
+	if pauseErr != nil || board.Pause() == nil {
+		t.Log("expected the game to pause once", pauseErr)
+		t.FailNow()
+	}
+	done := make(chan error)
+	go func() {
+		done <- board.ChooseCards()
+	}()
+	select {
+	case <-done:
+		t.Log("expected the round to wait while paused")
+		t.FailNow()
+	case <-time.After(100 * time.Millisecond):
+	}
+	resumeErr := board.Moderate("resume")
+	if resumeErr != nil {
+		t.Log("unexpected resume error:", resumeErr)
+		t.FailNow()
+	}
+	chooseErr := <-done
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.Resume() == nil {
+		t.Log("expected resuming a running game to fail")
+		t.FailNow()
+	}
+}
+
+func TestSkipJudge(t *testing.T) {
+	board, reader, _ := generateOnlineTestBoard(t)
+	for board.CurrentJudgeName() != "online player 0" {
+		board.ItterateJudge()
+	}
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if board.SkipJudge() == nil {
+		t.Log("expected skipping to fail while nobody is judging")
+		t.FailNow()
+	}
+
+	go func() {
+		skipToPlay(reader)
+		board.Moderate("skip")
+	}()
+	winner, judgeErr := board.Judge()
+	if judgeErr != nil {
+		t.Log(judgeErr)
+		t.FailNow()
+	}
+	if winner < 0 || winner >= board.PlayedCards.PlayerCount() {
+		t.Log("expected the fallback judge to pick a submission, received", winner)
+		t.FailNow()
+	}
+}
+
+func TestKickJudge(t *testing.T) {
+	board, reader, _ := generateOnlineTestBoard(t)
+	var announcements bytes.Buffer
+	board.SetLogger(log.New(&announcements, "", 0))
+	for board.CurrentJudgeName() != "online player 0" {
+		board.ItterateJudge()
+	}
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+
+	kicked := make(chan struct{})
+	go func() {
+		skipToPlay(reader)
+		board.Moderate("kick online player 0")
+		close(kicked)
+	}()
+	_, judgeErr := board.Judge()
+	<-kicked
+	if judgeErr != nil {
+		t.Log(judgeErr)
+		t.FailNow()
+	}
+	if !strings.Contains(announcements.String(), "online player 0 was kicked") {
+		t.Log("expected everyone to be told the judge was kicked, announced", announcements.String())
+		t.FailNow()
+	}
+}
+
+func TestEndGame(t *testing.T) {
+	board, reader, _ := generateOnlineTestBoard(t)
+	for board.CurrentJudgeName() != "online player 0" {
+		board.ItterateJudge()
+	}
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+
+	go func() {
+		skipToPlay(reader)
+		board.Moderate("end")
+	}()
+	_, judgeErr := board.Judge()
+	if judgeErr != model.ErrGameEnded || !board.Ended() {
+		t.Log("expected the game to end, received", judgeErr)
+		t.FailNow()
+	}
+	if board.ChooseCards() != model.ErrGameEnded {
+		t.Log("expected no more rounds after the game ended")
+		t.FailNow()
+	}
+	if board.End() == nil || board.Pause() == nil {
+		t.Log("expected an ended game to refuse moderation")
+		t.FailNow()
+	}
+	_, leaderErr := board.Leader()
+	if leaderErr != nil {
+		t.Log("unexpected leader error:", leaderErr)
+		t.FailNow()
+	}
+}
+
+func TestModerateUnknownCommand(t *testing.T) {
+	board, _, _ := generateOnlineTestBoard(t)
+	invalid := []string{"", "dance", "kick", "ban"}
+	for i := 0; i < len(invalid); i++ {
+		if board.Moderate(invalid[i]) == nil {
+			t.Log("expected the command to be rejected", invalid[i])
+			t.FailNow()
+		}
+	}
+}
@@ -1,22 +1,147 @@
 package model
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+/*
+The Network is shared between the listener goroutines and the game, so the 
+player registry is guarded by lock and must only be used through pointers.
+*/
 type Network struct {
-	players 	[]PlayerConnection
+	lock		sync.Mutex
+	players 	[]*PlayerConnection
+	spectators	[]*PlayerConnection
 	host		net.Conn
+	hostWriter	*MessageWriter
+	hostReader	*MessageReader
+	hostAddress	string
+	playerName	string
+	token		string
+	room		string
+	transport	Transport
+	requestID	int64
+	reserved	[]string
+	events		chan NetworkEvent
+	started		bool
+	heartbeat	time.Duration
+	maxMissed	int
+	capacity	int
+	password	string
+	failures	map[string]*joinFailures
+	banned		map[string]bool
+	adminPassword	string
+	adminHandler	func(command string) error
+	backup		string
+	backupAddress	string
+	backupFingerprint	string
+	backupListener	net.Listener
+	snapshot	*SnapshotPayload
+	observers	[]func(event NetworkEvent)
+	hostVersion	int
+	hostFeatures	[]string
+}
+
+/*
+Something that happened to the player registry, delivered on Events().
+*/
+type NetworkEvent struct {
+	Type string
+	PlayerName string
+	Text string
+	Err error
 }
 
+const (
+	EVENT_PLAYER_JOINED = "player joined"
+	EVENT_PLAYER_LEFT = "player left"
+	EVENT_PLAYER_RESUMED = "player resumed"
+	EVENT_PLAYER_LAGGING = "player lagging"
+	EVENT_PLAYER_LOST = "player lost"
+	EVENT_PLAYER_READY = "player ready"
+	EVENT_SPECTATOR_JOINED = "spectator joined"
+	EVENT_SPECTATOR_LEFT = "spectator left"
+	EVENT_CHAT = "chat"
+	EVENT_JOIN_REFUSED = "join refused"
+	EVENT_PLAYER_KICKED = "player kicked"
+	EVENT_ADMIN_COMMAND = "admin command"
+	EVENT_BACKUP_READY = "backup ready"
+	EVENT_ACCEPT_ERROR = "accept error"
+)
+
+/*
+Returned when a player tries to join after the game has started.
+*/
+var ErrGameStarted = errors.New("the game has already started")
+
+/*
+Returned when a player tries to join a game that has no free seats.
+*/
+var ErrGameFull = errors.New("the game is full")
+
+/*
+Returned when prompting a player whose connection has dropped, their seat is 
+kept until they resume with their token.
+*/
+var ErrDisconnected = errors.New("player is disconnected")
+
+/*
+Longest pause between retries after failing to accept a connection.
+*/
+const MAX_ACCEPT_DELAY = time.Second
+
+/*
+How many events are buffered before new events are dropped.
+*/
+const EVENT_BUFFER = 64
+
 type PlayerConnection struct {
 	playerName string
+	token string
+	tokenHash string
+	connected bool
+	ready bool
+	missed int
 	conn net.Conn
+	writer *MessageWriter
+	reader *MessageReader
+	inbox chan Message
+	version int
+	features []string
+	chatWindow time.Time
+	chatCount int
+	chatLock sync.Mutex
+	chatQueue []ChatPayload
+	chatSending bool
 }
 
+/*
+Returns a random token that lets a player resume their seat.
+*/
+func newResumeToken() (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+/*
+Returned by Play when the context is done before the player answers.
+*/
+var ErrTimeout = errors.New("player ran out of time")
+
 const (
 	CONN_HOST = "localhost"
 	CONN_PORT = "8080"
@@ -24,101 +149,963 @@ const (
 )
 
 /*
-Listener that throws incomming connections to the handler.
+Sets the transport used to listen for and dial connections.
+*/
+func (n *Network) SetTransport(transport Transport) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.transport = transport
+}
 
-Returns an error if the listener can not be initiated.
+/*
+Returns the transport in use, defaults to raw TCP if none has been set.
 */
-func (n *Network) Listener() error {
-	listen, err := net.Listen(CONN_TYPE, CONN_HOST+":"+CONN_PORT)
+func (n *Network) Transport() Transport {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.transport == nil {
+		n.transport = NewTCPTransport()
+	}
+	return n.transport
+}
+
+/*
+Opens a listener on address and throws incomming connections to the handler 
+in the background until the context is done. Use port 0 to let the system 
+choose a free port.
+
+Returns the address actually listened on, or an error if the listener can 
+not be initiated.
+*/
+func (n *Network) Listener(ctx context.Context, address string) (net.Addr, error) {
+	listen, err := n.Transport().Listen(address)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
 	}
+	go n.Serve(ctx, listen)
+	return listen.Addr(), nil
+}
+
+/*
+Accepts connections from an already open listener and throws them to the
+handler. Accept errors are delivered as events and retried with a growing 
+delay. The listener is closed when Serve returns.
+
+Returns nil once the context is done, or the error that closed the listener.
+*/
+func (n *Network) Serve(ctx context.Context, listen net.Listener) error {
+	go n.heartbeatLoop(ctx)
+	return acceptLoop(ctx, listen, n.handleConnection, func(err error) {
+		n.emit(NetworkEvent{Type: EVENT_ACCEPT_ERROR, Err: err})
+	})
+}
+
+/*
+Hands every accepted connection to handle in its own goroutine until the 
+context is done, reporting accept errors and retrying them with a growing 
+delay. The listener is closed when it returns.
+
+Returns nil once the context is done, or the error that closed the listener.
+*/
+func acceptLoop(ctx context.Context, listen net.Listener, handle func(net.Conn), acceptErr func(error)) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			listen.Close()
+		case <-stop:
+		}
+	}()
 	defer listen.Close()
 
+	var delay time.Duration
 	for {
 		conn, err := listen.Accept()
 		if err != nil {
-			panic(err)
+			if ctx.Err() != nil {
+				return nil
+			}
+			acceptErr(err)
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay < MAX_ACCEPT_DELAY {
+				delay *= 2
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
 		}
-		go n.handleConnection(conn)
+		delay = 0
+		go handle(conn)
 	}
 }
 
 /*
-Adds the connection to the network struct along with a generated player name.
+Limits how many players can join, zero allows any number of players.
 */
-func (n *Network) handleConnection(conn net.Conn) {
-	player := PlayerConnection{
-		playerName: "online player " + fmt.Sprint(n.CountOnlinePlayers()),
+func (n *Network) SetCapacity(capacity int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.capacity = capacity
+}
+
+/*
+Returns true once the game has started.
+*/
+func (n *Network) Started() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.started
+}
+
+/*
+Marks the game as started, players that try to join after this are refused.
+*/
+func (n *Network) StartGame() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.started = true
+}
+
+/*
+Returns the channel that join and leave events are delivered on. Events are 
+dropped if nobody reads them and the buffer is full.
+*/
+func (n *Network) Events() <-chan NetworkEvent {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.eventChannel()
+}
+
+/*
+Returns the event channel, creating it if needed. The lock must be held.
+*/
+func (n *Network) eventChannel() chan NetworkEvent {
+	if n.events == nil {
+		n.events = make(chan NetworkEvent, EVENT_BUFFER)
+	}
+	return n.events
+}
+
+/*
+Calls handler with every event as it is emitted, along with delivering it 
+on the Events channel. Handlers run on the goroutine that emits the event 
+and must not block.
+*/
+func (n *Network) Observe(handler func(event NetworkEvent)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.observers = append(n.observers, handler)
+}
+
+/*
+Delivers an event to the observers and on the channel without blocking the 
+caller.
+*/
+func (n *Network) emit(event NetworkEvent) {
+	n.lock.Lock()
+	events := n.eventChannel()
+	observers := n.observers
+	n.lock.Unlock()
+	for i := 0; i < len(observers); i++ {
+		observers[i](event)
+	}
+	select {
+	case events <- event:
+	default:
+	}
+}
+
+/*
+Reserves a name so that no joining player can take it, used for the host 
+player and bots.
+*/
+func (n *Network) ReserveName(name string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.reserved = append(n.reserved, name)
+}
+
+/*
+Checks that a joining player may use the name. The lock must be held.
+
+Returns an error explaining why the name was rejected.
+*/
+func (n *Network) validateName(name string) error {
+	nameErr := ValidatePlayerName(name)
+	if nameErr != nil {
+		return nameErr
+	}
+	for i := 0; i < len(n.reserved); i++ {
+		if n.reserved[i] == name {
+			return errors.New("name is unavailable")
+		}
+	}
+	if n.indexOf(name) >= 0 || n.spectatorIndex(name) >= 0 {
+		return errors.New("name is unavailable")
+	}
+	return nil
+}
+
+/*
+Adds a player to the registry if the name is available, the check and the 
+insert happen under the same lock so two players can not claim one name.
+
+Returns an error explaining why the name was rejected.
+*/
+func (n *Network) register(player *PlayerConnection) error {
+	n.lock.Lock()
+	var nameErr error
+	if n.started {
+		nameErr = ErrGameStarted
+	} else if n.capacity > 0 && len(n.players) >= n.capacity {
+		nameErr = ErrGameFull
+	} else {
+		nameErr = n.validateName(player.playerName)
+	}
+	if nameErr == nil {
+		player.connected = true
+		n.players = append(n.players, player)
+	}
+	n.lock.Unlock()
+	if nameErr != nil {
+		return nameErr
+	}
+	n.emit(NetworkEvent{Type: EVENT_PLAYER_JOINED, PlayerName: player.playerName})
+	return nil
+}
+
+/*
+Records that the player voted to start the game, votes after the game has 
+started are ignored.
+*/
+func (n *Network) markReady(player *PlayerConnection) {
+	n.lock.Lock()
+	vote := !n.started && !player.ready
+	player.ready = true
+	n.lock.Unlock()
+	if vote {
+		n.emit(NetworkEvent{Type: EVENT_PLAYER_READY, PlayerName: player.playerName})
+	}
+}
+
+/*
+Returns how many players voted to start the game.
+*/
+func (n *Network) CountReady() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	ready := 0
+	for i := 0; i < len(n.players); i++ {
+		if n.players[i].ready {
+			ready++
+		}
+	}
+	return ready
+}
+
+/*
+Returns true if there are players and all of them voted to start the game.
+*/
+func (n *Network) AllReady() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for i := 0; i < len(n.players); i++ {
+		if !n.players[i].ready {
+			return false
+		}
+	}
+	return len(n.players) > 0
+}
+
+/*
+Gives the seat matching the resume token to a new connection. A connection 
+still holding the seat is closed, since the token proves who the player is. 
+Seats restored from a snapshot are matched by the hash of the token.
+
+Returns an error if the token does not match any player.
+*/
+func (n *Network) resume(token string, player *PlayerConnection) error {
+	n.lock.Lock()
+	index := -1
+	for i := 0; i < len(n.players); i++ {
+		if n.players[i].token == token || (n.players[i].token == "" && n.players[i].tokenHash == hashToken(token)) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		n.lock.Unlock()
+		return errors.New("unknown resume token")
+	}
+	previous := n.players[index]
+	player.playerName = previous.playerName
+	player.token = token
+	player.connected = true
+	n.players[index] = player
+	n.lock.Unlock()
+
+	previous.conn.Close()
+	n.emit(NetworkEvent{Type: EVENT_PLAYER_RESUMED, PlayerName: player.playerName})
+	return nil
+}
+
+/*
+Handles a dropped connection. Before the game starts the player is removed, 
+once it has started the seat is kept and marked as disconnected so that the 
+player can resume it.
+*/
+func (n *Network) disconnect(player *PlayerConnection) {
+	n.lock.Lock()
+	started := n.started
+	index := n.indexOf(player.playerName)
+	current := index >= 0 && n.players[index] == player
+	if started && current {
+		player.connected = false
+	}
+	n.lock.Unlock()
+
+	if !started {
+		n.unregister(player)
+		return
+	}
+	player.conn.Close()
+	if current {
+		n.emit(NetworkEvent{Type: EVENT_PLAYER_LEFT, PlayerName: player.playerName})
+	}
+}
+
+/*
+Removes a player from the registry and closes the connection.
+*/
+func (n *Network) unregister(player *PlayerConnection) {
+	n.lock.Lock()
+	removed := false
+	for i := 0; i < len(n.players); i++ {
+		if n.players[i] == player {
+			n.players = append(n.players[:i], n.players[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	n.lock.Unlock()
+	player.conn.Close()
+	if removed {
+		n.emit(NetworkEvent{Type: EVENT_PLAYER_LEFT, PlayerName: player.playerName})
+	}
+}
+
+/*
+Creates the player side of a new connection.
+*/
+func newPlayerConnection(conn net.Conn, reader *MessageReader, writer *MessageWriter) *PlayerConnection {
+	return &PlayerConnection{
 		conn: conn,
+		writer: writer,
+		reader: reader,
+		inbox: make(chan Message, 16),
+		version: MIN_PROTOCOL_VERSION,
+		features: LEGACY_FEATURES,
+	}
+}
+
+/*
+Runs the join handshake on a newly accepted connection.
+*/
+func (n *Network) handleConnection(conn net.Conn) {
+	n.handshake(newPlayerConnection(conn, NewMessageReader(conn), NewMessageWriter(conn)))
+}
+
+/*
+How long a connection may stay quiet before it has joined, idle connections 
+are closed instead of holding on to a goroutine.
+*/
+const HANDSHAKE_TIMEOUT = 2 * time.Minute
+
+/*
+Performs the join handshake and adds the connection to the network struct 
+under the name the player chose. Rejected names are answered with the 
+reason so that the client can try again on the same connection.
+*/
+func (n *Network) handshake(player *PlayerConnection) {
+	conn := player.conn
+	for {
+		conn.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+		msg, err := player.reader.Receive()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if n.isBanned(conn.RemoteAddr()) {
+			if msg.Type == MSG_ADMIN {
+				player.writer.Send(MSG_ADMIN_RESULT, msg.ID, AdminResultPayload{Reason: ErrBanned.Error()})
+			} else {
+				player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: ErrBanned.Error()})
+			}
+			conn.Close()
+			return
+		}
+		if msg.Type == MSG_HELLO {
+			helloErr := answerHello(player, msg)
+			if helloErr != nil {
+				n.emit(NetworkEvent{Type: EVENT_JOIN_REFUSED, PlayerName: addressIP(conn.RemoteAddr()), Err: helloErr})
+				conn.Close()
+				return
+			}
+			continue
+		}
+		if msg.Type == MSG_ADMIN {
+			if n.handleAdmin(player, msg) == ErrTooManyAttempts {
+				conn.Close()
+				return
+			}
+			continue
+		}
+		if msg.Type != MSG_JOIN {
+			continue
+		}
+		var join JoinPayload
+		decodeErr := msg.Decode(&join)
+		if decodeErr != nil {
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: "invalid join message"})
+			continue
+		}
+
+		/*
+		A game with a password only lets joins with the password through, 
+		addresses that fail too often are disconnected.
+		===============================================================
+		*/
+		passwordErr := n.checkPassword(conn.RemoteAddr(), join.Password)
+		if passwordErr == ErrTooManyAttempts {
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: passwordErr.Error()})
+			n.emit(NetworkEvent{Type: EVENT_JOIN_REFUSED, PlayerName: join.Name, Err: passwordErr})
+			conn.Close()
+			return
+		}
+		if passwordErr != nil {
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: passwordErr.Error()})
+			continue
+		}
+
+		/*
+		A join with a token resumes an existing seat.
+		===============================================================
+		*/
+		if join.Token != "" {
+			resumeErr := n.resume(join.Token, player)
+			if resumeErr != nil {
+				player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: resumeErr.Error()})
+				continue
+			}
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{
+				Accepted: true,
+				Name: player.playerName,
+				Token: player.token,
+			})
+			player.writer.Send(MSG_DISPLAY, 0, DisplayPayload{
+				Lines: []string{"Welcome back " + player.playerName + ", you will rejoin at the next prompt."},
+			})
+			break
+		}
+
+		/*
+		A spectator watches without taking a seat.
+		===============================================================
+		*/
+		if join.Spectate {
+			if !player.supports(FEATURE_SPECTATE) {
+				player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: "your game does not support spectating, please update"})
+				continue
+			}
+			player.playerName = join.Name
+			spectateErr := n.addSpectator(player)
+			if spectateErr != nil {
+				player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: spectateErr.Error()})
+				continue
+			}
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{
+				Accepted: true,
+				Name: player.playerName,
+			})
+			conn.SetReadDeadline(time.Time{})
+			go n.spectatorLoop(player)
+			return
+		}
+
+		token, tokenErr := newResumeToken()
+		if tokenErr != nil {
+			conn.Close()
+			return
+		}
+		player.playerName = join.Name
+		player.token = token
+		nameErr := n.register(player)
+		if nameErr == ErrGameStarted || nameErr == ErrGameFull {
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: nameErr.Error()})
+			n.emit(NetworkEvent{Type: EVENT_JOIN_REFUSED, PlayerName: join.Name, Err: nameErr})
+			conn.Close()
+			return
+		}
+		if nameErr != nil {
+			player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{Reason: nameErr.Error()})
+			continue
+		}
+		sendErr := player.writer.Send(MSG_JOIN_RESULT, msg.ID, JoinResultPayload{
+			Accepted: true,
+			Name: player.playerName,
+			Token: player.token,
+		})
+		if sendErr != nil {
+			n.unregister(player)
+			return
+		}
+		break
 	}
-	n.players = append(n.players, player)
+	conn.SetReadDeadline(time.Time{})
+	go n.readLoop(player)
 }
 
 /*
-Establish a connection with the host.
+Reads every message from the connection, choices go into the inbox so that 
+a prompt can stop waiting without leaving a half read message behind. 
+Messages the host has no use for are dropped. The inbox is closed and the 
+player disconnected once the connection fails or the player leaves.
+*/
+func (n *Network) readLoop(player *PlayerConnection) {
+	defer n.disconnect(player)
+	defer close(player.inbox)
+	for {
+		msg, err := player.reader.Receive()
+		if err != nil {
+			return
+		}
+		if msg.Type == MSG_PONG {
+			n.pong(player)
+			continue
+		}
+		if msg.Type == MSG_LEAVE {
+			return
+		}
+		if msg.Type == MSG_READY {
+			n.markReady(player)
+			continue
+		}
+		if msg.Type == MSG_CHAT {
+			n.relayChat(player, msg)
+			continue
+		}
+		if msg.Type == MSG_ADMIN {
+			n.handleAdmin(player, msg)
+			continue
+		}
+		if msg.Type == MSG_BACKUP_READY {
+			n.backupReady(player, msg)
+			continue
+		}
+		if msg.Type == MSG_CHOICE {
+			player.queueChoice(msg)
+		}
+	}
+}
+
+/*
+Puts a choice in the inbox without waiting for a prompt to take it. Nothing 
+reads the inbox between prompts, so once it is full the oldest choice, 
+which no prompt is waiting for anymore, makes room.
+*/
+func (p *PlayerConnection) queueChoice(msg Message) {
+	for {
+		select {
+		case p.inbox <- msg:
+			return
+		default:
+		}
+		select {
+		case <-p.inbox:
+		default:
+		}
+	}
+}
+
+/*
+Establish a connection with the host at address, given as host:port, and 
+say hello to it.
 
 Returns an error if there is a problem with the dial function.
 */
-func (n *Network) DialHost() error {
-	conn, err := net.Dial(CONN_TYPE, CONN_HOST+":"+CONN_PORT)
+func (n *Network) DialHost(address string) error {
+	return n.dialHost(n.Transport(), address)
+}
+
+/*
+Dials the host at address over the given transport and says hello.
+*/
+func (n *Network) dialHost(transport Transport, address string) error {
+	conn, err := transport.Dial(address)
 	if err != nil {
 		return err
 	}
+	n.lock.Lock()
 	n.host = conn
-	return nil
+	n.hostWriter = NewMessageWriter(conn)
+	n.hostReader = NewMessageReader(conn)
+	n.hostReader.SetLimit(MAX_HOST_MESSAGE_SIZE)
+	n.hostAddress = address
+	n.lock.Unlock()
+	return n.sendHello()
+}
+
+/*
+Returns the name the host accepted, empty until the player joined.
+*/
+func (n *Network) PlayerName() string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.playerName
+}
+
+/*
+Asks the host to join under the given name. An accepted join stores the 
+resume token used by Reconnect.
+
+Returns whether the name was accepted along with the hosts reason for 
+rejecting it, or an error if the connection fails.
+*/
+func (n *Network) Join(name string) (bool, string, error) {
+	result, err := n.sendJoin(JoinPayload{Name: name})
+	if err != nil {
+		return false, "", err
+	}
+	if result.Accepted {
+		n.lock.Lock()
+		n.playerName = result.Name
+		n.token = result.Token
+		n.lock.Unlock()
+	}
+	return result.Accepted, result.Reason, nil
+}
+
+/*
+Sends a join message with the password of the game to the host and waits 
+for the result.
+*/
+func (n *Network) sendJoin(join JoinPayload) (JoinResultPayload, error) {
+	n.lock.Lock()
+	join.Password = n.password
+	n.lock.Unlock()
+	var result JoinResultPayload
+	err := n.request(MSG_JOIN, join, MSG_JOIN_RESULT, &result)
+	return result, err
 }
 
-func (n *Network) Respond(response string) error {
+/*
+Sends a message to the host and decodes the first reply of the given type 
+into reply, other messages received in the meantime are skipped. The answer 
+to the hello is picked up on the way.
+
+Returns an error if the connection fails, the reply is invalid or the host 
+rejected the hello.
+*/
+func (n *Network) request(msgType string, payload interface{}, replyType string, reply interface{}) error {
+	n.lock.Lock()
+	writer, reader := n.hostWriter, n.hostReader
+	n.lock.Unlock()
+
+	sendErr := writer.Send(msgType, 0, payload)
+	if sendErr != nil {
+		return sendErr
+	}
+	for {
+		msg, err := reader.Receive()
+		if err != nil {
+			return err
+		}
+		if msg.Type == MSG_HELLO_RESULT {
+			helloErr := n.helloResult(msg)
+			if helloErr != nil {
+				return helloErr
+			}
+			continue
+		}
+		if msg.Type != replyType {
+			continue
+		}
+		return msg.Decode(reply)
+	}
+}
+
+/*
+Dials the host again after the connection dropped and resumes the seat with 
+the token from the original join, retrying a number of times with a delay 
+in between. Once the host has named a backup, every attempt that fails to 
+reach the host tries the backup too, which takes over hosting if the host 
+went away for good.
+
+Returns an error if the player never joined or the seat could not be resumed.
+*/
+func (n *Network) Reconnect(attempts int, delay time.Duration) error {
+	n.lock.Lock()
+	address, token, name, room := n.hostAddress, n.token, n.playerName, n.room
+	backup, fingerprint := n.backupAddress, n.backupFingerprint
+	if n.host != nil {
+		n.host.Close()
+	}
+	n.lock.Unlock()
+	if token == "" {
+		return errors.New("no resume token, the game was never joined")
+	}
+	hostTransport := n.Transport()
+	backupTransport := hostTransport
+	if hostTransport.TLS() != nil && fingerprint != "" {
+		backupTransport = hostTransport.WithTLS(ClientTLSConfig(fingerprint))
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		retry, resumeErr := n.resumeAt(hostTransport, address, room, name, token)
+		if resumeErr == nil {
+			return nil
+		}
+		if !retry {
+			return resumeErr
+		}
+		lastErr = resumeErr
+		if backup == "" {
+			continue
+		}
+
+		retry, resumeErr = n.resumeAt(backupTransport, backup, "", name, token)
+		if resumeErr == nil {
+			// the backup is the host from now on
+			n.lock.Lock()
+			n.transport = backupTransport
+			n.room = ""
+			n.backupAddress = ""
+			n.backupFingerprint = ""
+			n.lock.Unlock()
+			return nil
+		}
+		if !retry {
+			return resumeErr
+		}
+		lastErr = resumeErr
+	}
+	return lastErr
+}
+
+/*
+Dials the host at address over the given transport and resumes the seat, 
+entering the room first when the host is a lobby. The host has 
+RESUME_TIMEOUT to answer.
+
+Returns nil once the seat is resumed, or an error along with whether it is 
+worth trying again. A host that refuses the resume is not.
+*/
+func (n *Network) resumeAt(transport Transport, address string, room string, name string, token string) (bool, error) {
+	dialErr := n.dialHost(transport, address)
+	if dialErr != nil {
+		return true, dialErr
+	}
+	n.lock.Lock()
 	conn := n.host
-	_, respErr := conn.Write([]byte(response))
-	if respErr != nil {
-		return respErr
+	n.lock.Unlock()
+	conn.SetDeadline(time.Now().Add(RESUME_TIMEOUT))
+	if room != "" {
+		entered, enterErr := n.EnterRoom(room)
+		if enterErr != nil {
+			conn.Close()
+			return true, enterErr
+		}
+		if !entered.Accepted {
+			conn.Close()
+			return false, errors.New("could not resume the game, " + entered.Reason)
+		}
 	}
-	return nil
+	result, joinErr := n.sendJoin(JoinPayload{Name: name, Token: token})
+	if joinErr != nil {
+		conn.Close()
+		return true, joinErr
+	}
+	if !result.Accepted {
+		conn.Close()
+		return false, errors.New("could not resume the game, " + result.Reason)
+	}
+	conn.SetDeadline(time.Time{})
+	return true, nil
+}
+
+/*
+Votes to start the game.
+*/
+func (n *Network) Ready() error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	return writer.Send(MSG_READY, 0, nil)
+}
+
+/*
+Answers the play message with the given request ID.
+*/
+func (n *Network) Respond(id int, input string) error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	return writer.Send(MSG_CHOICE, id, ChoicePayload{Input: input})
 }
 
-func (n *Network) Listen() net.Conn {
-	return n.host
+/*
+Blocks until the next message from the host arrives, the answer to the 
+hello is handled and not returned.
+
+Returns an error if the connection fails or the host rejected the hello.
+*/
+func (n *Network) Receive() (Message, error) {
+	n.lock.Lock()
+	reader := n.hostReader
+	n.lock.Unlock()
+	for {
+		msg, err := reader.Receive()
+		if err != nil || msg.Type != MSG_HELLO_RESULT {
+			return msg, err
+		}
+		helloErr := n.helloResult(msg)
+		if helloErr != nil {
+			return Message{}, helloErr
+		}
+	}
 }
 
 /*
 Returns how many player connections have been established.
 */
 func (n *Network) CountOnlinePlayers() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 	return len(n.players)
 }
 
 /*
-Sends a CLI prompt to a player and awaits an integer response.
+Returns a new request ID for a play message.
+*/
+func (n *Network) nextRequestID() int {
+	return int(atomic.AddInt64(&n.requestID, 1))
+}
+
+/*
+Parses a players answer and checks that it is one of the offered options.
+
+Returns an error describing why the answer was rejected.
+*/
+func parseChoice(input string, validOptions int) (int, error) {
+	choice, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil {
+		return 0, errors.New("\"" + input + "\" is not a number")
+	}
+	if choice < 0 || choice >= validOptions {
+		return 0, errors.New(fmt.Sprint(choice) + " is not an option, choose between 0 and " + fmt.Sprint(validOptions-1))
+	}
+	return choice, nil
+}
+
+/*
+Sends a CLI prompt to a player and awaits an integer response between 0 and 
+validOptions-1. Invalid answers are rejected and the player is prompted 
+again with an explanation, replies to earlier requests are ignored. If the 
+context is done first the prompt is cancelled on the client.
 
 Returns various potential errors.
+	* If there are no options to choose from an error is returned.
 	* If the players connection can not be found an error is returned.
-	* If the connections returns an error it is passed along.
-	* If the response parsing returns an error it is passed along.
+	* If the player is disconnected or the connection fails ErrDisconnected is returned.
+	* If the context is done ErrTimeout is returned.
 */
-func (n *Network) Play(playerName string, prompt string) (int, error) {
-	playerIndex, err := n.findPlayer(playerName)
+func (n *Network) Play(ctx context.Context, playerName string, validOptions int, prompt []string) (int, error) {
+	if validOptions <= 0 {
+		return 0, errors.New("no options to choose from")
+	}
+	payload := PlayPayload{
+		ValidOptions: validOptions,
+		Lines: prompt,
+	}
+	return n.prompt(ctx, playerName, payload, func(choice ChoicePayload) (int, error) {
+		return parseChoice(choice.Input, validOptions)
+	})
+}
+
+/*
+Sends the play message to a player until pick accepts the answer, see Play. 
+The round is left out for players that do not support rich cards.
+*/
+func (n *Network) prompt(ctx context.Context, playerName string, payload PlayPayload, pick func(choice ChoicePayload) (int, error)) (int, error) {
+	player, err := n.connectedPlayer(playerName)
 	if err != nil {
 		return 0, err
 	}
-	prompt = "Play\n" + prompt
-	conn := n.players[playerIndex].conn
-	_, sendErr := conn.Write([]byte(prompt))
-	if sendErr != nil {
-		return 0, sendErr
+	if !player.supports(FEATURE_RICH_CARDS) {
+		payload.Round = nil
 	}
-	response := make([]byte, 4096)
-	_, listErr := conn.Read(response)
-	if listErr != nil {
-		return 0, listErr
+	for {
+		id := n.nextRequestID()
+		sendErr := player.writer.Send(MSG_PLAY, id, payload)
+		if sendErr != nil {
+			// the read loop notices the closed connection and frees the seat for a resume
+			player.conn.Close()
+			return 0, ErrDisconnected
+		}
+		choice, listErr := n.awaitChoice(ctx, player, id)
+		if listErr == ErrTimeout && player.supports(FEATURE_TIMERS) {
+			player.writer.Send(MSG_CANCEL, id, nil)
+		}
+		if listErr != nil {
+			return 0, listErr
+		}
+		respInt, parseErr := pick(choice)
+		if parseErr != nil {
+			payload.Error = parseErr.Error()
+			continue
+		}
+		return respInt, nil
 	}
-	respInt, err :=  strconv.Atoi(string(response[0]))
-	if err != nil {
-		return 0, err
+}
+
+/*
+Waits for the choice answering request id, or for the context to be done.
+*/
+func (n *Network) awaitChoice(ctx context.Context, player *PlayerConnection, id int) (ChoicePayload, error) {
+	for {
+		var msg Message
+		select {
+		case <-ctx.Done():
+			return ChoicePayload{}, ErrTimeout
+		case received, ok := <-player.inbox:
+			if !ok {
+				return ChoicePayload{}, ErrDisconnected
+			}
+			msg = received
+		}
+		if msg.Type != MSG_CHOICE || msg.ID != id {
+			continue
+		}
+		var choice ChoicePayload
+		decodeErr := msg.Decode(&choice)
+		if decodeErr != nil {
+			// a malformed answer is treated like any other invalid input
+			return ChoicePayload{}, nil
+		}
+		return choice, nil
 	}
-	return int(respInt), nil
 }
 
 /*
@@ -127,79 +1114,120 @@ Sends a display message to a player.
 Returns an error if the player can not be found or the connection returns an error.
 */
 func (n *Network) Display(playerName string, info string) error {
-	playerIndex, err := n.findPlayer(playerName)
+	player, err := n.connectedPlayer(playerName)
 	if err != nil {
 		return err
 	}
-	info = "Display\n" + info
-	conn := n.players[playerIndex].conn
-	_, sendErr := conn.Write([]byte(info))
-	if sendErr != nil {
-		return sendErr
-	}
-	return nil
+	return player.writer.Send(MSG_DISPLAY, 0, DisplayPayload{
+		Lines: strings.Split(info, "\n"),
+	})
 }
 
 /*
-Send out standard info messages to all online players.
+Send out standard info messages to all online players and spectators, 
+disconnected players are skipped.
+
+Returns the first error from a connected player, after trying everyone.
 */
 func (n *Network) MassDisplay(info string) error {
-	for i := 0; i < len(n.players); i++ {
-		connErr := n.Display(n.players[i].playerName, info)
-		if connErr != nil {
-			return connErr
+	n.SpectatorDisplay(info)
+	var firstErr error
+	players := n.ListPlayers()
+	for i := 0; i < len(players); i++ {
+		connErr := n.Display(players[i], info)
+		if connErr != nil && connErr != ErrDisconnected && firstErr == nil {
+			firstErr = connErr
 		}
 	}
-	return nil
+	return firstErr
 }
 
-func (n *Network) End(playerName string, info string) error {
-	playerIndex, err := n.findPlayer(playerName)
+/*
+Tells a player that the game is over and who won.
+*/
+func (n *Network) End(playerName string, winner string) error {
+	player, err := n.connectedPlayer(playerName)
 	if err != nil {
 		return err
 	}
-	info = "End\n" + info
-	conn := n.players[playerIndex].conn
-	_, sendErr := conn.Write([]byte(info))
-	if sendErr != nil {
-		return sendErr
-	}
-	return nil
+	return player.writer.Send(MSG_END, 0, EndPayload{Winner: winner})
 }
 
 func (n *Network) GameOver(winner string) {
-	for i := 0; i < len(n.players); i++ {
-		n.End(n.players[i].playerName, winner)
+	players := n.ListPlayers()
+	for i := 0; i < len(players); i++ {
+		n.End(players[i], winner)
+	}
+	spectators := n.spectatorConnections()
+	for i := 0; i < len(spectators); i++ {
+		spectators[i].writer.Send(MSG_END, 0, EndPayload{Winner: winner})
 	}
 }
 
-func (n  *Network) findPlayer(name string) (int, error) {
+/*
+Returns the index of the named player in the registry, or -1. The lock must 
+be held.
+*/
+func (n *Network) indexOf(name string) int {
 	for i := 0; i < len(n.players); i++ {
 		if n.players[i].playerName == name {
-			return i, nil
+			return i
 		}
 	}
-	return -1, errors.New("did not find online player by name")
+	return -1
 }
 
-func (n *Network) findPlayerName(index int) (string, error) {
-	if index < 0 || index >= n.CountOnlinePlayers() {
-		return "", errors.New("index out of bounds")
+/*
+Returns the connection of the named player.
+
+Returns an error if there is no such player.
+*/
+func (n *Network) findPlayer(name string) (*PlayerConnection, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	index := n.indexOf(name)
+	if index < 0 {
+		return nil, errors.New("did not find online player by name")
 	}
-	return n.players[index].playerName, nil
+	return n.players[index], nil
 }
 
 /*
-Returns a list of all online player's names
+Returns the connection of the named player if they are connected.
+
+Returns ErrDisconnected if the players connection has dropped.
+*/
+func (n *Network) connectedPlayer(name string) (*PlayerConnection, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	index := n.indexOf(name)
+	if index < 0 {
+		return nil, errors.New("did not find online player by name")
+	}
+	if !n.players[index].connected {
+		return nil, ErrDisconnected
+	}
+	return n.players[index], nil
+}
+
+/*
+Returns true if the named player has a working connection.
+*/
+func (n *Network) IsConnected(name string) bool {
+	_, err := n.connectedPlayer(name)
+	return err == nil
+}
+
+/*
+Returns a list of all online player's names, including disconnected players 
+that still hold a seat.
 */
 func (n *Network) ListPlayers() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 	var playerList []string
-	for i := 0; i < n.CountOnlinePlayers(); i++ {
-		name, err := n.findPlayerName(i)
-		if err != nil {
-			continue
-		}
-		playerList = append(playerList, name)
+	for i := 0; i < len(n.players); i++ {
+		playerList = append(playerList, n.players[i].playerName)
 	}
 	return playerList
 }
@@ -208,7 +1236,11 @@ func (n *Network) ListPlayers() []string {
 Close the connections to all online players.
 */
 func (n *Network) CloseConnections() {
-	for i := 0; i < len(n.players); i++ {
-		n.players[i].conn.Close()
+	n.lock.Lock()
+	players := append([]*PlayerConnection{}, n.players...)
+	players = append(players, n.spectators...)
+	n.lock.Unlock()
+	for i := 0; i < len(players); i++ {
+		players[i].conn.Close()
 	}
-}
\ No newline at end of file
+}
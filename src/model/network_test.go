@@ -0,0 +1,621 @@
+package model_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"main/model"
+	"net"
+	"testing"
+	"time"
+)
+
+/*
+Starts a network on a random loopback port and connects a raw client to it.
+*/
+func generateTestNetwork(t *testing.T) (*model.Network, net.Conn, *model.MessageReader, *model.MessageWriter) {
+	transport := model.NewTCPTransport()
+	listen, listenErr := transport.Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("incorrect test config,", listenErr)
+		t.FailNow()
+	}
+	network := new(model.Network)
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	go network.Serve(ctx, listen)
+
+	conn, dialErr := transport.Dial(listen.Addr().String())
+	if dialErr != nil {
+		t.Log("incorrect test config,", dialErr)
+		t.FailNow()
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader, writer := model.NewMessageReader(conn), model.NewMessageWriter(conn)
+	accepted, reason := joinTestClient(t, reader, writer, "online player 0")
+	if !accepted {
+		t.Log("test client was rejected:", reason)
+		t.FailNow()
+	}
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_JOINED || event.PlayerName != "online player 0" {
+		t.Log("test client did not connect", event)
+		t.FailNow()
+	}
+	return network, conn, reader, writer
+}
+
+/*
+Waits for the next event from the network.
+*/
+func awaitEvent(t *testing.T, network *model.Network) model.NetworkEvent {
+	select {
+	case event := <-network.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Log("timed out waiting for a network event")
+		t.FailNow()
+	}
+	return model.NetworkEvent{}
+}
+
+/*
+Sends a join message and returns the hosts answer.
+*/
+func joinTestClient(t *testing.T, reader *model.MessageReader, writer *model.MessageWriter, name string) (bool, string) {
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: name})
+	return result.Accepted, result.Reason
+}
+
+/*
+Sends a join message and returns the complete join result.
+*/
+func sendTestJoin(t *testing.T, reader *model.MessageReader, writer *model.MessageWriter, join model.JoinPayload) model.JoinResultPayload {
+	writer.Send(model.MSG_JOIN, 0, join)
+	msg, err := reader.Receive()
+	if err != nil {
+		t.Log("unexpected receive error:", err)
+		t.FailNow()
+	}
+	if msg.Type != model.MSG_JOIN_RESULT {
+		t.Log("expected a join result, received", msg.Type)
+		t.FailNow()
+	}
+	var result model.JoinResultPayload
+	msg.Decode(&result)
+	return result
+}
+
+/*
+Connects a raw client to the same host as conn.
+*/
+func dialTestClient(t *testing.T, conn net.Conn) (net.Conn, *model.MessageReader, *model.MessageWriter) {
+	client, dialErr := model.NewTCPTransport().Dial(conn.RemoteAddr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return client, model.NewMessageReader(client), model.NewMessageWriter(client)
+}
+
+/*
+Reads the next play message sent to the test client.
+*/
+func receivePlay(t *testing.T, reader *model.MessageReader) (model.Message, model.PlayPayload) {
+	msg, err := reader.Receive()
+	if err != nil {
+		t.Log("unexpected receive error:", err)
+		t.FailNow()
+	}
+	if msg.Type != model.MSG_PLAY {
+		t.Log("expected a play message, received", msg.Type)
+		t.FailNow()
+	}
+	var play model.PlayPayload
+	decodeErr := msg.Decode(&play)
+	if decodeErr != nil {
+		t.Log("unexpected decode error:", decodeErr)
+		t.FailNow()
+	}
+	return msg, play
+}
+
+func TestPlayMultiDigitChoice(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+
+	result := make(chan int)
+	go func() {
+		choice, err := network.Play(context.Background(), "online player 0", 12, []string{"pick one"})
+		if err != nil {
+			result <- -1
+			return
+		}
+		result <- choice
+	}()
+
+	msg, play := receivePlay(t, reader)
+	if play.ValidOptions != 12 || play.Error != "" {
+		t.Log("unexpected play payload", play)
+		t.FailNow()
+	}
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "11"})
+	if choice := <-result; choice != 11 {
+		t.Log("expected choice 11, received", choice)
+		t.FailNow()
+	}
+}
+
+func TestStaleMessagesDropped(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+
+	// stale choices and unknown messages arrive between prompts
+	for i := 0; i < 100; i++ {
+		writer.Send(model.MSG_CHOICE, 1000+i, model.ChoicePayload{Input: "0"})
+		writer.Send(model.MSG_DISPLAY, 0, model.DisplayPayload{Lines: []string{"noise"}})
+	}
+	writer.Send(model.MSG_CHAT, 0, model.ChatPayload{Text: "still here"})
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_CHAT {
+		t.Log("expected the chat to be handled after the stale messages, received", event)
+		t.FailNow()
+	}
+	receiveChat(t, reader, "online player 0", "still here")
+
+	result := make(chan int)
+	go func() {
+		choice, _ := network.Play(context.Background(), "online player 0", 3, []string{"pick one"})
+		result <- choice
+	}()
+	msg, _ := receivePlay(t, reader)
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "2"})
+	if choice := <-result; choice != 2 {
+		t.Log("expected choice 2, received", choice)
+		t.FailNow()
+	}
+}
+
+func TestPlayRepromptsInvalidChoice(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+
+	result := make(chan int)
+	go func() {
+		choice, err := network.Play(context.Background(), "online player 0", 4, []string{"pick one"})
+		if err != nil {
+			result <- -1
+			return
+		}
+		result <- choice
+	}()
+
+	invalid := []string{"x", "4", "-1", ""}
+	msg, _ := receivePlay(t, reader)
+	for i := 0; i < len(invalid); i++ {
+		writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: invalid[i]})
+		var reprompt model.PlayPayload
+		msg, reprompt = receivePlay(t, reader)
+		if reprompt.Error == "" {
+			t.Log("expected an explanation for rejecting", invalid[i])
+			t.FailNow()
+		}
+		if reprompt.ValidOptions != 4 {
+			t.Log("expected the same options to be offered again")
+			t.FailNow()
+		}
+	}
+
+	// a reply to an earlier request must be ignored
+	writer.Send(model.MSG_CHOICE, msg.ID-1, model.ChoicePayload{Input: "0"})
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "2"})
+	if choice := <-result; choice != 2 {
+		t.Log("expected choice 2, received", choice)
+		t.FailNow()
+	}
+}
+
+func TestPlayWithoutOptions(t *testing.T) {
+	network := new(model.Network)
+	_, err := network.Play(context.Background(), "online player 0", 0, []string{})
+	if err == nil {
+		t.Log("did not catch play without options")
+		t.FailNow()
+	}
+}
+
+func TestPlayTimeout(t *testing.T) {
+	network, conn, reader, _ := generateTestNetwork(t)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, playErr := network.Play(ctx, "online player 0", 3, []string{"pick one"})
+	if playErr != model.ErrTimeout {
+		t.Log("expected a timeout, received", playErr)
+		t.FailNow()
+	}
+
+	msg, _ := receivePlay(t, reader)
+	cancelMsg, err := reader.Receive()
+	if err != nil {
+		t.Log("unexpected receive error:", err)
+		t.FailNow()
+	}
+	if cancelMsg.Type != model.MSG_CANCEL || cancelMsg.ID != msg.ID {
+		t.Log("expected the client prompt to be cancelled")
+		t.FailNow()
+	}
+}
+
+func TestJoinHandshake(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.ReserveName("host")
+
+	transport := model.NewTCPTransport()
+	second, dialErr := transport.Dial(conn.RemoteAddr().String())
+	if dialErr != nil {
+		t.Log("incorrect test config,", dialErr)
+		t.FailNow()
+	}
+	defer second.Close()
+	second.SetDeadline(time.Now().Add(5 * time.Second))
+	reader, writer := model.NewMessageReader(second), model.NewMessageWriter(second)
+
+	rejected := []string{"", "online player 0", "host", "bad/name", " padded", "a name that is far too long"}
+	for i := 0; i < len(rejected); i++ {
+		accepted, reason := joinTestClient(t, reader, writer, rejected[i])
+		if accepted {
+			t.Log("expected the name", rejected[i], "to be rejected")
+			t.FailNow()
+		}
+		if reason == "" {
+			t.Log("expected a reason for rejecting", rejected[i])
+			t.FailNow()
+		}
+	}
+	accepted, reason := joinTestClient(t, reader, writer, "second_player-2")
+	if !accepted {
+		t.Log("expected the name to be accepted, rejected with", reason)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+	players := network.ListPlayers()
+	if len(players) != 2 || players[1] != "second_player-2" {
+		t.Log("expected the player to be registered under the chosen name", players)
+		t.FailNow()
+	}
+}
+
+func TestListenerAnyPort(t *testing.T) {
+	network := new(model.Network)
+	address, listenErr := network.Listener(context.Background(), "127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("unexpected listen error:", listenErr)
+		t.FailNow()
+	}
+	_, port, _ := net.SplitHostPort(address.String())
+	if port == "0" || port == "" {
+		t.Log("expected the chosen port to be returned, received", address)
+		t.FailNow()
+	}
+	conn, dialErr := new(model.TCPTransport).Dial(address.String())
+	if dialErr != nil {
+		t.Log("could not connect to the returned address:", dialErr)
+		t.FailNow()
+	}
+	conn.Close()
+}
+
+func TestPlayerLeftEvent(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	conn.Close()
+
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LEFT || event.PlayerName != "online player 0" {
+		t.Log("expected a leave event, received", event)
+		t.FailNow()
+	}
+	if network.CountOnlinePlayers() != 0 {
+		t.Log("expected the player to be removed from the registry")
+		t.FailNow()
+	}
+}
+
+/*
+Many clients racing for the same name, exactly one of them may get it.
+*/
+func TestConcurrentJoinSameName(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	defer network.CloseConnections()
+
+	const clients = 8
+	results := make(chan bool, clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			client, dialErr := model.NewTCPTransport().Dial(conn.RemoteAddr().String())
+			if dialErr != nil {
+				results <- false
+				return
+			}
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+			reader, writer := model.NewMessageReader(client), model.NewMessageWriter(client)
+			writer.Send(model.MSG_JOIN, 0, model.JoinPayload{Name: "popular"})
+			msg, err := reader.Receive()
+			var result model.JoinResultPayload
+			if err == nil {
+				msg.Decode(&result)
+			}
+			results <- result.Accepted
+		}()
+	}
+	accepted := 0
+	for i := 0; i < clients; i++ {
+		if <-results {
+			accepted++
+		}
+	}
+	if accepted != 1 {
+		t.Log("expected exactly one client to get the name, accepted", accepted)
+		t.FailNow()
+	}
+	if network.CountOnlinePlayers() != 2 {
+		t.Log("expected two registered players, found", network.CountOnlinePlayers())
+		t.FailNow()
+	}
+}
+
+func TestServeStopsOnCancel(t *testing.T) {
+	listen, listenErr := model.NewTCPTransport().Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("incorrect test config,", listenErr)
+		t.FailNow()
+	}
+	network := new(model.Network)
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- network.Serve(ctx, listen)
+	}()
+	stop()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Log("expected a clean stop, received", err)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("listener did not stop when the context was cancelled")
+		t.FailNow()
+	}
+	_, dialErr := model.NewTCPTransport().Dial(listen.Addr().String())
+	if dialErr == nil {
+		t.Log("expected the listener to be closed")
+		t.FailNow()
+	}
+}
+
+/*
+Listener that fails a number of times before handing out its connections.
+*/
+type failingListener struct {
+	net.Listener
+	failures int
+}
+
+func (fl *failingListener) Accept() (net.Conn, error) {
+	if fl.failures > 0 {
+		fl.failures--
+		return nil, errors.New("too many open files")
+	}
+	return fl.Listener.Accept()
+}
+
+func TestServeAcceptErrorEvent(t *testing.T) {
+	listen, listenErr := model.NewTCPTransport().Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("incorrect test config,", listenErr)
+		t.FailNow()
+	}
+	network := new(model.Network)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go network.Serve(ctx, &failingListener{Listener: listen, failures: 2})
+
+	for i := 0; i < 2; i++ {
+		event := awaitEvent(t, network)
+		if event.Type != model.EVENT_ACCEPT_ERROR || event.Err == nil {
+			t.Log("expected an accept error event, received", event)
+			t.FailNow()
+		}
+	}
+
+	conn, dialErr := model.NewTCPTransport().Dial(listen.Addr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	accepted, reason := joinTestClient(t, model.NewMessageReader(conn), model.NewMessageWriter(conn), "survivor")
+	if !accepted {
+		t.Log("expected the listener to keep accepting after errors,", reason)
+		t.FailNow()
+	}
+}
+
+func TestJoinAfterGameStarted(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.StartGame()
+
+	late, dialErr := model.NewTCPTransport().Dial(conn.RemoteAddr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	defer late.Close()
+	late.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := model.NewMessageReader(late)
+	accepted, reason := joinTestClient(t, reader, model.NewMessageWriter(late), "latecomer")
+	if accepted || reason != model.ErrGameStarted.Error() {
+		t.Log("expected the late join to be refused, received", accepted, reason)
+		t.FailNow()
+	}
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_JOIN_REFUSED || event.PlayerName != "latecomer" {
+		t.Log("expected a refused join event, received", event)
+		t.FailNow()
+	}
+	if network.CountOnlinePlayers() != 1 {
+		t.Log("expected the late player not to be registered")
+		t.FailNow()
+	}
+	_, closedErr := reader.Receive()
+	if closedErr == nil {
+		t.Log("expected the late connection to be closed")
+		t.FailNow()
+	}
+}
+
+func TestResumeAfterDisconnect(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	client, reader, writer := dialTestClient(t, conn)
+	joined := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "flaky"})
+	if !joined.Accepted || joined.Name != "flaky" || joined.Token == "" {
+		t.Log("expected an accepted join with a resume token, received", joined)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+	network.StartGame()
+
+	client.Close()
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_LEFT || event.PlayerName != "flaky" {
+		t.Log("expected a leave event, received", event)
+		t.FailNow()
+	}
+	if network.IsConnected("flaky") || network.CountOnlinePlayers() != 2 {
+		t.Log("expected the seat to be kept for the disconnected player")
+		t.FailNow()
+	}
+	_, playErr := network.Play(context.Background(), "flaky", 3, []string{"pick"})
+	if playErr != model.ErrDisconnected {
+		t.Log("expected ErrDisconnected while the player is away, received", playErr)
+		t.FailNow()
+	}
+
+	_, reader, writer = dialTestClient(t, conn)
+	resumed := sendTestJoin(t, reader, writer, model.JoinPayload{Token: joined.Token})
+	if !resumed.Accepted || resumed.Name != "flaky" || resumed.Token != joined.Token {
+		t.Log("expected the token to resume the seat, received", resumed)
+		t.FailNow()
+	}
+	event = awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_RESUMED || event.PlayerName != "flaky" {
+		t.Log("expected a resume event, received", event)
+		t.FailNow()
+	}
+	welcome, _ := reader.Receive()
+	if welcome.Type != model.MSG_DISPLAY {
+		t.Log("expected a welcome back message, received", welcome.Type)
+		t.FailNow()
+	}
+
+	result := make(chan int, 1)
+	go func() {
+		choice, _ := network.Play(context.Background(), "flaky", 3, []string{"pick"})
+		result <- choice
+	}()
+	msg, _ := receivePlay(t, reader)
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "2"})
+	if choice := <-result; choice != 2 {
+		t.Log("expected the resumed player to play, received", choice)
+		t.FailNow()
+	}
+}
+
+func TestResumeUnknownToken(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.StartGame()
+
+	_, reader, writer := dialTestClient(t, conn)
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "online player 0", Token: "forged"})
+	if result.Accepted {
+		t.Log("expected an unknown token to be rejected")
+		t.FailNow()
+	}
+	if network.CountOnlinePlayers() != 1 || !network.IsConnected("online player 0") {
+		t.Log("expected the existing seat to be untouched")
+		t.FailNow()
+	}
+}
+
+func TestReadyVote(t *testing.T) {
+	network, conn, _, writer := generateTestNetwork(t)
+	defer conn.Close()
+	_, reader, secondWriter := dialTestClient(t, conn)
+	joinTestClient(t, reader, secondWriter, "second")
+	awaitEvent(t, network)
+	if network.AllReady() {
+		t.Log("expected nobody to be ready before voting")
+		t.FailNow()
+	}
+
+	writer.Send(model.MSG_READY, 0, nil)
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_READY || event.PlayerName != "online player 0" {
+		t.Log("expected a ready event, received", event)
+		t.FailNow()
+	}
+	if network.CountReady() != 1 || network.AllReady() {
+		t.Log("expected one of two players to be ready")
+		t.FailNow()
+	}
+
+	secondWriter.Send(model.MSG_READY, 0, nil)
+	event = awaitEvent(t, network)
+	if event.Type != model.EVENT_PLAYER_READY || event.PlayerName != "second" {
+		t.Log("expected a ready event, received", event)
+		t.FailNow()
+	}
+	if !network.AllReady() {
+		t.Log("expected everyone to be ready")
+		t.FailNow()
+	}
+}
+
+func TestHandshakeMessageTooLarge(t *testing.T) {
+	_, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	client, reader, _ := dialTestClient(t, conn)
+
+	// a join that never ends
+	go func() {
+		client.Write([]byte("{\"type\":\"join\",\"payload\":{\"name\":\""))
+		chunk := bytes.Repeat([]byte("a"), 1024)
+		for {
+			_, writeErr := client.Write(chunk)
+			if writeErr != nil {
+				return
+			}
+		}
+	}()
+	_, closedErr := reader.Receive()
+	if closedErr == nil {
+		t.Log("expected the connection to be closed")
+		t.FailNow()
+	}
+	var timeout net.Error
+	if errors.As(closedErr, &timeout) && timeout.Timeout() {
+		t.Log("expected the host to close the connection instead of buffering the join")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+)
+
+/*
+Characters of an invite code, without the ones that are easily mixed up
+when read out loud.
+*/
+const INVITE_CODE_ALPHABET = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const INVITE_CODE_LENGTH = 6
+
+/*
+Failed joins an address may make in a row before it is locked out, and for
+how long it is locked out.
+*/
+const (
+	MAX_JOIN_FAILURES = 5
+	JOIN_LOCKOUT = time.Minute
+)
+
+/*
+Returned when a join does not present the password of the game.
+*/
+var ErrWrongPassword = errors.New("wrong password")
+
+/*
+Returned when an address has failed to present the password too many times.
+*/
+var ErrTooManyAttempts = errors.New("too many failed attempts, try again later")
+
+/*
+Failed joins from one address, the address is locked out until the given
+time once it has failed too often. Last is when it last failed.
+*/
+type joinFailures struct {
+	count int
+	until time.Time
+	last time.Time
+}
+
+/*
+Sets the password of the game. On the host every join has to present it,
+an empty password lets anyone join. On a client it is sent with every join.
+*/
+func (n *Network) SetPassword(password string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.password = password
+}
+
+/*
+Generates a short random invite code and sets it as the password of the
+game.
+
+Returns the code, or an error if no random code could be generated.
+*/
+func (n *Network) GenerateInviteCode() (string, error) {
+	code := make([]byte, INVITE_CODE_LENGTH)
+	max := big.NewInt(int64(len(INVITE_CODE_ALPHABET)))
+	for i := 0; i < len(code); i++ {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = INVITE_CODE_ALPHABET[index.Int64()]
+	}
+	n.SetPassword(string(code))
+	return string(code), nil
+}
+
+/*
+Checks the password presented by a join from the given address, see 
+checkAttempt.
+*/
+func (n *Network) checkPassword(address net.Addr, password string) error {
+	n.lock.Lock()
+	want := n.password
+	n.lock.Unlock()
+	return n.checkAttempt(address, want, password, ErrWrongPassword)
+}
+
+/*
+Checks a secret presented by the given address, an empty secret lets 
+everyone through. Addresses that fail MAX_JOIN_FAILURES times in a row are 
+refused for JOIN_LOCKOUT without checking the secret. Failures are 
+forgotten once an address has been quiet for JOIN_LOCKOUT.
+
+Returns ErrTooManyAttempts while the address is locked out, wrongErr if the 
+secret does not match, or nil if it does.
+*/
+func (n *Network) checkAttempt(address net.Addr, secret string, presented string, wrongErr error) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if secret == "" {
+		return nil
+	}
+	now := time.Now()
+	n.pruneFailures(now)
+	ip := addressIP(address)
+	failures := n.failures[ip]
+	if failures != nil && now.Before(failures.until) {
+		return ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) == 1 {
+		delete(n.failures, ip)
+		return nil
+	}
+
+	if n.failures == nil {
+		n.failures = make(map[string]*joinFailures)
+	}
+	if failures == nil {
+		failures = new(joinFailures)
+		n.failures[ip] = failures
+	}
+	failures.count++
+	failures.last = now
+	if failures.count >= MAX_JOIN_FAILURES {
+		failures.count = 0
+		failures.until = now.Add(JOIN_LOCKOUT)
+		return ErrTooManyAttempts
+	}
+	return wrongErr
+}
+
+/*
+Removes the addresses that are not locked out and have not failed within 
+JOIN_LOCKOUT, so that the failures of many addresses do not pile up. Call 
+with the lock held.
+*/
+func (n *Network) pruneFailures(now time.Time) {
+	for ip, failures := range n.failures {
+		if now.After(failures.until) && now.Sub(failures.last) > JOIN_LOCKOUT {
+			delete(n.failures, ip)
+		}
+	}
+}
+
+/*
+Returns the IP of an address without the port, so that every connection
+from the same machine counts towards the same limit.
+*/
+func addressIP(address net.Addr) string {
+	if address == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(address.String())
+	if err != nil {
+		return address.String()
+	}
+	return host
+}
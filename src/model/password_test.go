@@ -0,0 +1,102 @@
+package model_test
+
+import (
+	"main/model"
+	"strings"
+	"testing"
+)
+
+func TestPasswordJoin(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.SetPassword("secret")
+
+	_, reader, writer := dialTestClient(t, conn)
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "guest"})
+	if result.Accepted || result.Reason != model.ErrWrongPassword.Error() {
+		t.Log("expected a join without the password to be rejected", result)
+		t.FailNow()
+	}
+	result = sendTestJoin(t, reader, writer, model.JoinPayload{Name: "guest", Password: "secret"})
+	if !result.Accepted {
+		t.Log("expected a join with the password to be accepted", result)
+		t.FailNow()
+	}
+
+	client := new(model.Network)
+	client.SetPassword("secret")
+	dialErr := client.DialHost(conn.RemoteAddr().String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	accepted, reason, joinErr := client.Join("client")
+	if joinErr != nil || !accepted {
+		t.Log("expected the client to send its password", reason, joinErr)
+		t.FailNow()
+	}
+}
+
+func TestInviteCode(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	code, codeErr := network.GenerateInviteCode()
+	if codeErr != nil {
+		t.Log("unexpected invite code error:", codeErr)
+		t.FailNow()
+	}
+	if len(code) != model.INVITE_CODE_LENGTH {
+		t.Log("unexpected invite code length", code)
+		t.FailNow()
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(model.INVITE_CODE_ALPHABET, c) {
+			t.Log("unexpected character in invite code", code)
+			t.FailNow()
+		}
+	}
+
+	_, reader, writer := dialTestClient(t, conn)
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "guest", Password: code})
+	if !result.Accepted {
+		t.Log("expected a join with the invite code to be accepted", result)
+		t.FailNow()
+	}
+}
+
+func TestPasswordRateLimit(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.SetPassword("secret")
+
+	_, reader, writer := dialTestClient(t, conn)
+	for i := 1; i < model.MAX_JOIN_FAILURES; i++ {
+		result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "guest", Password: "guess"})
+		if result.Reason != model.ErrWrongPassword.Error() {
+			t.Log("expected a wrong password, received", result)
+			t.FailNow()
+		}
+	}
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "guest", Password: "guess"})
+	if result.Reason != model.ErrTooManyAttempts.Error() {
+		t.Log("expected the address to be locked out, received", result)
+		t.FailNow()
+	}
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_JOIN_REFUSED || event.Err != model.ErrTooManyAttempts {
+		t.Log("expected a refused join event, received", event)
+		t.FailNow()
+	}
+	_, closedErr := reader.Receive()
+	if closedErr == nil {
+		t.Log("expected the locked out connection to be closed")
+		t.FailNow()
+	}
+
+	_, retryReader, retryWriter := dialTestClient(t, conn)
+	result = sendTestJoin(t, retryReader, retryWriter, model.JoinPayload{Name: "guest", Password: "secret"})
+	if result.Accepted || result.Reason != model.ErrTooManyAttempts.Error() {
+		t.Log("expected the address to stay locked out with the right password", result)
+		t.FailNow()
+	}
+}
@@ -83,6 +83,17 @@ func (pa *PlayedApples) DisplayApples() ([]string, error) {
 	return apples, nil
 }
 
+/*
+Returns the submitted cards in the order the judge sees them.
+*/
+func (pa *PlayedApples) submittedCards() []Card {
+	cards := make([]Card, len(pa.pp))
+	for i := 0; i < len(pa.pp); i++ {
+		cards[i] = pa.pp[i].card
+	}
+	return cards
+}
+
 /*
 Returns the player name of the chosen index, usefull for showing who 
 won the round when the judge chooses a winning card.
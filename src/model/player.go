@@ -1,6 +1,12 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+	"unicode"
+)
+
+const MAX_NAME_LENGTH = 20
 
 type Player struct {
 	name string
@@ -9,13 +15,16 @@ type Player struct {
 	hand []Card
 	handCapacity int
 	points []Card
+	controller PlayerController
 }
 
 /*
-Creates and returns a new player.
+Creates and returns a new player. Bots are decided for by a BotController 
+and the host through the terminal, online players get their controller 
+from the network of the board, see Board.SetNetwork.
 */
 func NewPlayer(playerName string, host bool, bot bool, handCapacity int) *Player {
-	return &Player{
+	player := &Player{
 		name: playerName,
 		host: host,
 		bot: bot,
@@ -23,6 +32,36 @@ func NewPlayer(playerName string, host bool, bot bool, handCapacity int) *Player
 		handCapacity: handCapacity,
 		points: *new([]Card),
 	}
+	if bot {
+		player.controller = NewBotController()
+	} else if host {
+		player.controller = NewTerminalController()
+	}
+	return player
+}
+
+/*
+Checks that a player name is between 1 and MAX_NAME_LENGTH characters and 
+only contains letters, digits, spaces, dashes and underscores.
+
+Returns an error describing what is wrong with the name.
+*/
+func ValidatePlayerName(name string) error {
+	if name == "" {
+		return errors.New("name can not be empty")
+	}
+	if len([]rune(name)) > MAX_NAME_LENGTH {
+		return errors.New("name can be at most " + strconv.Itoa(MAX_NAME_LENGTH) + " characters")
+	}
+	if name[0] == ' ' || name[len(name)-1] == ' ' {
+		return errors.New("name can not start or end with a space")
+	}
+	for _, char := range name {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != ' ' && char != '-' && char != '_' {
+			return errors.New("name can only contain letters, digits, spaces, dashes and underscores")
+		}
+	}
+	return nil
 }
 
 /*
@@ -46,6 +85,21 @@ func (p *Player) Bot() bool {
 	return p.bot
 }
 
+/*
+Sets the controller that decides for the player, see PlayerController. 
+Every player on a board that plays rounds needs one.
+*/
+func (p *Player) SetController(controller PlayerController) {
+	p.controller = controller
+}
+
+/*
+Returns the controller set for the player, or nil if there is none.
+*/
+func (p *Player) Controller() PlayerController {
+	return p.controller
+}
+
 /*
 Returns the players hand capacity.
 */
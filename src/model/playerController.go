@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+
+	"main/view"
+)
+
+/*
+What a player is notified about.
+*/
+const (
+	NOTICE_INFO = "info"
+	NOTICE_WAITING = "waiting"
+)
+
+/*
+Decides for a player during a round. The board asks the controller of each
+player instead of checking what kind of player it is, so new kinds of
+players only need a new controller, see Player.SetController.
+
+ChooseCard returns the position of a card in round.Hand and Judge the
+position of the winner in round.Submissions. Both return ErrTimeout if the
+context is done first, and ErrDisconnected if the player can not be reached.
+*/
+type PlayerController interface {
+	ChooseCard(ctx context.Context, round RoundPayload) (int, error)
+	Judge(ctx context.Context, round RoundPayload) (int, error)
+	Notify(kind string, info string) error
+}
+
+/*
+Plays for the host player through the terminal.
+*/
+type TerminalController struct{}
+
+/*
+Creates and returns a new terminal controller.
+*/
+func NewTerminalController() *TerminalController {
+	return &TerminalController{}
+}
+
+func (c *TerminalController) ChooseCard(ctx context.Context, round RoundPayload) (int, error) {
+	cardIndex, err := view.ChooseCard(ctx, displayCard(round.GreenApple), displayCards(round.Hand))
+	return cardIndex, terminalErr(ctx, err)
+}
+
+func (c *TerminalController) Judge(ctx context.Context, round RoundPayload) (int, error) {
+	winner, err := view.JudgeCards(ctx, displayCard(round.GreenApple), displayCards(round.Submissions))
+	return winner, terminalErr(ctx, err)
+}
+
+/*
+Returns ErrTimeout if the terminal stopped waiting because the context is 
+done, otherwise the error of the terminal, such as it being closed.
+*/
+func terminalErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ErrTimeout
+	}
+	return err
+}
+
+func (c *TerminalController) Notify(kind string, info string) error {
+	if kind == NOTICE_WAITING {
+		view.WaitPlayerCards()
+		return nil
+	}
+	view.Announce(info)
+	return nil
+}
+
+/*
+Plays for an online player by prompting them over the network.
+*/
+type NetworkController struct {
+	network *Network
+	playerName string
+}
+
+/*
+Creates and returns a controller for the online player with the given name.
+*/
+func NewNetworkController(network *Network, playerName string) *NetworkController {
+	return &NetworkController{
+		network: network,
+		playerName: playerName,
+	}
+}
+
+func (c *NetworkController) ChooseCard(ctx context.Context, round RoundPayload) (int, error) {
+	if c.network == nil {
+		return 0, errors.New("board has no network")
+	}
+	return c.network.PlayCards(ctx, c.playerName, round, roundPrompt(round, round.Hand, "Please select a card to play:"))
+}
+
+func (c *NetworkController) Judge(ctx context.Context, round RoundPayload) (int, error) {
+	if c.network == nil {
+		return 0, errors.New("board has no network")
+	}
+	return c.network.PlayCards(ctx, c.playerName, round, roundPrompt(round, round.Submissions, "Select the winning card:"))
+}
+
+func (c *NetworkController) Notify(kind string, info string) error {
+	if c.network == nil {
+		return nil
+	}
+	return c.network.Display(c.playerName, info)
+}
+
+/*
+Plays random cards.
+*/
+type BotController struct{}
+
+/*
+Creates and returns a new bot controller.
+*/
+func NewBotController() *BotController {
+	return &BotController{}
+}
+
+func (c *BotController) ChooseCard(ctx context.Context, round RoundPayload) (int, error) {
+	if len(round.Hand) == 0 {
+		return 0, errors.New("no cards to choose from")
+	}
+	return rand.Intn(len(round.Hand)), nil
+}
+
+func (c *BotController) Judge(ctx context.Context, round RoundPayload) (int, error) {
+	if len(round.Submissions) == 0 {
+		return 0, errors.New("no cards to choose from")
+	}
+	return rand.Intn(len(round.Submissions)), nil
+}
+
+func (c *BotController) Notify(kind string, info string) error {
+	return nil
+}
+
+/*
+Returns the controller of a player.
+
+Returns an error if no controller was set for the player.
+*/
+func controllerOf(player *Player) (PlayerController, error) {
+	if player.controller == nil {
+		return nil, errors.New(player.PlayerName() + " has no controller")
+	}
+	return player.controller, nil
+}
+
+/*
+Renders the round as the text prompt sent to clients without rich cards.
+*/
+func roundPrompt(round RoundPayload, cards []CardPayload, instruction string) []string {
+	prompt := []string{"Current green apple - " + displayCard(round.GreenApple)}
+	for i := 0; i < len(cards); i++ {
+		prompt = append(prompt, "[" + strconv.Itoa(i) + "]" + displayCard(cards[i]))
+	}
+	return append(prompt, instruction)
+}
+
+func displayCard(card CardPayload) string {
+	return card.Header + " - " + card.Description
+}
+
+func displayCards(cards []CardPayload) []string {
+	display := make([]string, len(cards))
+	for i := 0; i < len(cards); i++ {
+		display[i] = displayCard(cards[i])
+	}
+	return display
+}
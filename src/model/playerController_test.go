@@ -0,0 +1,147 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+A controller that always picks the same positions and remembers what it
+was asked and told.
+*/
+type scriptedController struct {
+	lock sync.Mutex
+	card int
+	winner int
+	judgeErr error
+	rounds []model.RoundPayload
+	notices []string
+}
+
+func (c *scriptedController) ChooseCard(ctx context.Context, round model.RoundPayload) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rounds = append(c.rounds, round)
+	return c.card, nil
+}
+
+func (c *scriptedController) Judge(ctx context.Context, round model.RoundPayload) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rounds = append(c.rounds, round)
+	return c.winner, c.judgeErr
+}
+
+func (c *scriptedController) Notify(kind string, info string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.notices = append(c.notices, kind)
+	return nil
+}
+
+/*
+Creates an offline board where every player is played by its own scripted
+controller, with a green apple on the board and "scripted 0" as the judge.
+*/
+func generateScriptedBoard(t *testing.T) (*model.Board, []*scriptedController) {
+	board, controllers := newScriptedBoard(t)
+	board.DrawGreenApple()
+	return board, controllers
+}
+
+/*
+Creates an offline board where every player is played by its own scripted
+controller, with full hands, "scripted 0" as the judge and the win
+condition set.
+*/
+func newScriptedBoard(t *testing.T) (*model.Board, []*scriptedController) {
+	var players []model.Player
+	var controllers []*scriptedController
+	for i := 0; i < 4; i++ {
+		controller := &scriptedController{card: i, winner: 1}
+		player := model.NewPlayer("scripted "+string(rune('0'+i)), false, false, 7)
+		player.SetController(controller)
+		players = append(players, *player)
+		controllers = append(controllers, controller)
+	}
+	return newTestBoard(t, players...), controllers
+}
+
+func TestScriptedControllers(t *testing.T) {
+	board, controllers := generateScriptedBoard(t)
+	played := make(map[int]int)
+	for i := 1; i < 4; i++ {
+		hand, _ := board.PlayersHand("scripted " + string(rune('0'+i)))
+		played[hand[i].ID()] = i
+	}
+
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	if len(controllers[0].rounds) != 0 || len(controllers[0].notices) != 1 || controllers[0].notices[0] != model.NOTICE_WAITING {
+		t.Log("expected the judge to wait instead of playing a card")
+		t.FailNow()
+	}
+	for i := 1; i < 4; i++ {
+		if len(controllers[i].rounds) != 1 || controllers[i].rounds[0].Action != model.ACTION_PLAY_CARD {
+			t.Log("expected scripted", i, "to be asked for a card once")
+			t.FailNow()
+		}
+	}
+
+	winner, judgeErr := board.Judge()
+	if judgeErr != nil || winner != 1 {
+		t.Log("expected the scripted judge to pick the winner, received", winner, judgeErr)
+		t.FailNow()
+	}
+	round := controllers[0].rounds[0]
+	if round.Action != model.ACTION_JUDGE || len(round.Submissions) != 3 {
+		t.Log("expected the judge to be shown the submissions, received", round)
+		t.FailNow()
+	}
+	// every player played the card at the position their controller picked
+	for _, submission := range round.Submissions {
+		if _, ok := played[submission.ID]; !ok {
+			t.Log("expected", submission.Header, "to be one of the scripted cards")
+			t.FailNow()
+		}
+	}
+}
+
+func TestScriptedJudgeTimeout(t *testing.T) {
+	board, controllers := generateScriptedBoard(t)
+	controllers[0].judgeErr = model.ErrTimeout
+	board.SetFallbackJudge(func(redApples []string) int {
+		return 2
+	})
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	winner, judgeErr := board.Judge()
+	if judgeErr != nil || winner != 2 {
+		t.Log("expected the fallback judge to pick the winner, received", winner, judgeErr)
+		t.FailNow()
+	}
+}
+
+func TestMissingController(t *testing.T) {
+	board, _ := generateScriptedBoard(t)
+	board.AddPlayer(*model.NewPlayer("uncontrolled", false, false, 7))
+	board.FillHands()
+	chooseErr := board.ChooseCards()
+	if chooseErr == nil || !strings.Contains(chooseErr.Error(), "uncontrolled") {
+		t.Log("expected the round to refuse a player without a controller, received", chooseErr)
+		t.FailNow()
+	}
+	if board.PlayedCards.PlayerCount() != 0 {
+		t.Log("expected nobody to be asked for a card")
+		t.FailNow()
+	}
+}
@@ -223,4 +223,21 @@ func TestShowHand(t *testing.T) {
 			t.FailNow()
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestValidatePlayerName(t *testing.T) {
+	valid := []string{"player one", "Bot0", "Åsa", "dash-and_underscore"}
+	for i := 0; i < len(valid); i++ {
+		if model.ValidatePlayerName(valid[i]) != nil {
+			t.Log("expected", valid[i], "to be a valid name")
+			t.FailNow()
+		}
+	}
+	invalid := []string{"", " leading", "trailing ", "new\nline", "semi;colon", "twenty one characters"}
+	for i := 0; i < len(invalid); i++ {
+		if model.ValidatePlayerName(invalid[i]) == nil {
+			t.Log("expected", invalid[i], "to be an invalid name")
+			t.FailNow()
+		}
+	}
+}
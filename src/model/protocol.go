@@ -0,0 +1,523 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+Every message exchanged between host and client is a Message envelope,
+encoded as one JSON object per line. The type decides which payload
+struct the payload holds, and the ID ties a reply to the request it answers.
+
+	hello        client -> host  HelloPayload       protocol version and features of the client
+	hello_result host -> client  HelloResultPayload the version and features both sides use
+	join         client -> host  JoinPayload        ask to join under a name
+	join_result  host -> client  JoinResultPayload  accept or reject the join
+	play         host -> client  PlayPayload        prompt the player for a choice
+	choice       client -> host  ChoicePayload      answer to the play with the same ID
+	cancel       host -> client  no payload         the play with the same ID ran out of time
+	display      host -> client  DisplayPayload     information to show the player
+	end          host -> client  EndPayload         the game is over
+	ping         host -> client  no payload         check that the client is still there
+	pong         client -> host  no payload         answer to the ping with the same ID
+	leave        client -> host  no payload         the player leaves the game
+	ready        client -> host  no payload         the player votes to start the game
+	chat         both            ChatPayload        a chat message, relayed by the host to everyone
+	admin        client -> host  AdminPayload       a moderation command for the host to run
+	admin_result host -> client  AdminResultPayload whether the command was run
+	snapshot     host -> client  SnapshotPayload    the game as of this round, sent to the backup
+	backup_ready client -> host  BackupReadyPayload the backup is ready to take over hosting
+	backup       host -> client  BackupPayload      where to reconnect if the host goes away
+
+A lobby server hosts many games in named rooms. Clients of a lobby pick a 
+room before the join handshake, after entering a room the room behaves like 
+any other host.
+
+	list_rooms   client -> lobby no payload         ask for the open rooms
+	rooms        lobby -> client RoomsPayload       the open rooms
+	create_room  client -> lobby CreateRoomPayload  open a new room and enter it
+	enter_room   client -> lobby EnterRoomPayload   enter an existing room
+	room_result  lobby -> client RoomResultPayload  accept or reject entering a room
+
+Hosts also advertise their game on the local network, one message per UDP 
+broadcast datagram, so that players can find it without knowing the address.
+
+	announce     host -> LAN     AnnouncePayload    a game that can be joined
+*/
+type Message struct {
+	Type    string          `json:"type"`
+	ID      int             `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	MSG_HELLO        = "hello"
+	MSG_HELLO_RESULT = "hello_result"
+	MSG_JOIN         = "join"
+	MSG_JOIN_RESULT  = "join_result"
+	MSG_PLAY         = "play"
+	MSG_CHOICE       = "choice"
+	MSG_CANCEL       = "cancel"
+	MSG_DISPLAY      = "display"
+	MSG_END          = "end"
+	MSG_PING         = "ping"
+	MSG_PONG         = "pong"
+	MSG_LEAVE        = "leave"
+	MSG_READY        = "ready"
+	MSG_CHAT         = "chat"
+	MSG_ADMIN        = "admin"
+	MSG_ADMIN_RESULT = "admin_result"
+	MSG_SNAPSHOT     = "snapshot"
+	MSG_BACKUP_READY = "backup_ready"
+	MSG_BACKUP       = "backup"
+	MSG_LIST_ROOMS   = "list_rooms"
+	MSG_ROOMS        = "rooms"
+	MSG_CREATE_ROOM  = "create_room"
+	MSG_ENTER_ROOM   = "enter_room"
+	MSG_ROOM_RESULT  = "room_result"
+	MSG_ANNOUNCE     = "announce"
+)
+
+/*
+The first message of a client, before anything else. Version is the newest 
+protocol version the client speaks and MinVersion the oldest, Features the 
+optional features it supports, see SUPPORTED_FEATURES.
+*/
+type HelloPayload struct {
+	Version    int      `json:"version"`
+	MinVersion int      `json:"minVersion"`
+	Features   []string `json:"features"`
+}
+
+/*
+The hosts answer to a hello, Reason explains why the client can not play 
+with this host. Accepted hellos carry the protocol version and the features 
+both sides use from now on.
+*/
+type HelloResultPayload struct {
+	Accepted bool     `json:"accepted"`
+	Reason   string   `json:"reason,omitempty"`
+	Version  int      `json:"version,omitempty"`
+	Features []string `json:"features,omitempty"`
+}
+
+/*
+The name a player wants to join the game under. A join with the token from 
+an earlier join resumes that players seat instead, and a spectator joins to 
+watch without taking a seat. Games with a password or invite code only 
+accept joins that carry it.
+*/
+type JoinPayload struct {
+	Name     string `json:"name"`
+	Token    string `json:"token,omitempty"`
+	Spectate bool   `json:"spectate,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+/*
+The hosts answer to a join, Reason explains a rejection. Accepted joins 
+carry the players name and the token needed to resume after a disconnect.
+*/
+type JoinResultPayload struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+/*
+Prompt for a choice, valid answers are the integers 0 to ValidOptions-1.
+Error explains why the previous answer was rejected when re-prompting.
+Prompts for a card also carry the Round for clients that support rich 
+cards, the Lines are the same round rendered as text.
+*/
+type PlayPayload struct {
+	ValidOptions int           `json:"validOptions"`
+	Lines        []string      `json:"lines"`
+	Error        string        `json:"error,omitempty"`
+	Round        *RoundPayload `json:"round,omitempty"`
+}
+
+/*
+What to do with the cards of a round.
+*/
+const (
+	ACTION_PLAY_CARD = "play_card"
+	ACTION_JUDGE     = "judge"
+)
+
+/*
+The state of a round for a prompt, Action is ACTION_PLAY_CARD to pick a 
+card from the Hand or ACTION_JUDGE to pick the winner of the Submissions. 
+Submissions are anonymous and only sent to the judge.
+*/
+type RoundPayload struct {
+	Action      string         `json:"action"`
+	Round       int            `json:"round"`
+	Judge       string         `json:"judge"`
+	GreenApple  CardPayload    `json:"greenApple"`
+	Hand        []CardPayload  `json:"hand"`
+	Submissions []CardPayload  `json:"submissions,omitempty"`
+	Scores      []ScorePayload `json:"scores"`
+}
+
+/*
+A card as shown to a player, ID identifies it within its deck.
+*/
+type CardPayload struct {
+	ID          int    `json:"id"`
+	Header      string `json:"header"`
+	Description string `json:"description"`
+}
+
+type ScorePayload struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+/*
+The players answer to a play message, as entered. Answers to a prompt with 
+a Round name the chosen card by its ID instead.
+*/
+type ChoicePayload struct {
+	Input  string `json:"input"`
+	CardID int    `json:"cardId,omitempty"`
+}
+
+/*
+Lines of information to show the player.
+*/
+type DisplayPayload struct {
+	Lines []string `json:"lines"`
+}
+
+/*
+Name of the player that won the game. Reason explains why the game ended 
+for the player when it did not end with a winner, for example a kick.
+*/
+type EndPayload struct {
+	Winner string `json:"winner"`
+	Reason string `json:"reason,omitempty"`
+}
+
+/*
+A chat message. Clients only send the text, the host fills in the sender 
+and the time before relaying it.
+*/
+type ChatPayload struct {
+	From string    `json:"from,omitempty"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time,omitempty"`
+}
+
+/*
+A moderation command, such as "kick Bob", sent with the admin password of 
+the game.
+*/
+type AdminPayload struct {
+	Password string `json:"password"`
+	Command  string `json:"command"`
+}
+
+/*
+The hosts answer to an admin command, Reason explains why it was not run.
+*/
+type AdminResultPayload struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+/*
+The state of a game at the start of a round, enough for the backup to take 
+over hosting. The backup is an ordinary player, so the snapshot only holds
+what every player can see: hands, the order of the decks and the password
+are left out, and the backup deals new hands from its own decks when it
+takes over. Resume tokens are only sent as hashes, so the backup can check
+the tokens of reconnecting players without being able to use them.
+*/
+type SnapshotPayload struct {
+	Players      []SnapshotPlayer `json:"players"`
+	Judge        string           `json:"judge"`
+	WinCondition int              `json:"winCondition"`
+	TurnTimeout  time.Duration    `json:"turnTimeout"`
+	JudgeTimeout time.Duration    `json:"judgeTimeout"`
+	BotCover     bool             `json:"botCover,omitempty"`
+	Heartbeat    time.Duration    `json:"heartbeat,omitempty"`
+	MaxMissed    int              `json:"maxMissed,omitempty"`
+	Round        int              `json:"round,omitempty"`
+}
+
+/*
+A seat on the board, online players carry the hash of their resume token.
+*/
+type SnapshotPlayer struct {
+	Name         string         `json:"name"`
+	Host         bool           `json:"host,omitempty"`
+	Bot          bool           `json:"bot,omitempty"`
+	HandCapacity int            `json:"handCapacity"`
+	Points       []SnapshotCard `json:"points"`
+	TokenHash    string         `json:"tokenHash,omitempty"`
+}
+
+type SnapshotCard struct {
+	ID          int    `json:"id,omitempty"`
+	Header      string `json:"header"`
+	Description string `json:"description"`
+}
+
+/*
+The port the backup listens on for players once it has taken over, and 
+the fingerprint of its certificate if the game uses TLS.
+*/
+type BackupReadyPayload struct {
+	Port        int    `json:"port"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+/*
+The address of the backup host, players reconnect there with their resume 
+token if the host goes away.
+*/
+type BackupPayload struct {
+	Address     string `json:"address"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+/*
+A room on a lobby server, Players is how many have joined out of Capacity.
+*/
+type RoomInfo struct {
+	Name     string `json:"name"`
+	Players  int    `json:"players"`
+	Capacity int    `json:"capacity"`
+	Started  bool   `json:"started"`
+}
+
+/*
+The rooms open on a lobby server.
+*/
+type RoomsPayload struct {
+	Rooms []RoomInfo `json:"rooms"`
+}
+
+/*
+Name of a new room and how many online players it waits for before the 
+game starts.
+*/
+type CreateRoomPayload struct {
+	Name    string `json:"name"`
+	Players int    `json:"players"`
+}
+
+/*
+Name of the room to enter.
+*/
+type EnterRoomPayload struct {
+	Name string `json:"name"`
+}
+
+/*
+The lobbys answer to creating or entering a room, Reason explains a 
+rejection.
+*/
+type RoomResultPayload struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+/*
+A game advertised on the local network. The game is reached on Port at the 
+address the announcement came from, over Transport ("tcp" or "websocket") 
+on Path. Seats is how many players can still join.
+*/
+type AnnouncePayload struct {
+	Name      string `json:"name"`
+	Port      int    `json:"port"`
+	Transport string `json:"transport"`
+	Path      string `json:"path,omitempty"`
+	TLS       bool   `json:"tls,omitempty"`
+	Password  bool   `json:"password,omitempty"`
+	Players   int    `json:"players"`
+	Seats     int    `json:"seats"`
+	Started   bool   `json:"started,omitempty"`
+}
+
+/*
+Creates a new message with the payload encoded as JSON.
+
+Returns an error if the payload can not be encoded.
+*/
+func NewMessage(msgType string, id int, payload interface{}) (Message, error) {
+	if msgType == "" {
+		return Message{}, errors.New("message type is required")
+	}
+	msg := Message{
+		Type: msgType,
+		ID:   id,
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Payload = data
+	}
+	return msg, nil
+}
+
+/*
+Decodes the payload of the message into the given payload struct.
+
+Returns an error if the message has no payload or it does not match.
+*/
+func (m Message) Decode(payload interface{}) error {
+	if len(m.Payload) == 0 {
+		return errors.New("message " + m.Type + " has no payload")
+	}
+	return json.Unmarshal(m.Payload, payload)
+}
+
+/*
+How long writing a message to a connection may take, a peer that stops 
+reading can otherwise block everyone sending to it.
+*/
+const WRITE_TIMEOUT = 10 * time.Second
+
+/*
+Encodes messages onto a connection, safe for concurrent use.
+*/
+type MessageWriter struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	conn net.Conn
+	timeout time.Duration
+}
+
+/*
+Creates and returns a new message writer for w. Writes to a connection 
+fail after WRITE_TIMEOUT.
+*/
+func NewMessageWriter(w io.Writer) *MessageWriter {
+	mw := &MessageWriter{
+		encoder: json.NewEncoder(w),
+		timeout: WRITE_TIMEOUT,
+	}
+	if conn, ok := w.(net.Conn); ok {
+		mw.conn = conn
+	}
+	return mw
+}
+
+/*
+Sets how long writing a message may take, zero waits forever.
+*/
+func (mw *MessageWriter) SetTimeout(timeout time.Duration) {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+	mw.timeout = timeout
+}
+
+/*
+Writes a complete message. If the write to the connection fails, for 
+example because it timed out, the connection is closed since part of the 
+message may already be sent.
+*/
+func (mw *MessageWriter) Write(msg Message) error {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+	if mw.conn != nil && mw.timeout > 0 {
+		mw.conn.SetWriteDeadline(time.Now().Add(mw.timeout))
+	}
+	err := mw.encoder.Encode(msg)
+	if err != nil && mw.conn != nil {
+		mw.conn.Close()
+	}
+	return err
+}
+
+/*
+Builds a message from the payload and writes it.
+
+Returns an error if the payload can not be encoded or the write fails.
+*/
+func (mw *MessageWriter) Send(msgType string, id int, payload interface{}) error {
+	msg, err := NewMessage(msgType, id, payload)
+	if err != nil {
+		return err
+	}
+	return mw.Write(msg)
+}
+
+/*
+Largest message read from a peer, and from a host, whose snapshots carry 
+every deck. A peer sending anything larger is cut off instead of being 
+buffered in memory.
+*/
+const (
+	MAX_MESSAGE_SIZE = 8 * 1024
+	MAX_HOST_MESSAGE_SIZE = 4 * 1024 * 1024
+)
+
+/*
+Returned when a message is larger than the reader allows, the stream can 
+not be read any further.
+*/
+var ErrMessageTooLarge = errors.New("message is too large")
+
+/*
+Decodes messages from a connection, regardless of how the stream was split
+or coalesced by the underlying reads.
+*/
+type MessageReader struct {
+	decoder *json.Decoder
+	limited *io.LimitedReader
+	limit int64
+}
+
+/*
+Creates and returns a new message reader for r, that reads messages of up 
+to MAX_MESSAGE_SIZE.
+*/
+func NewMessageReader(r io.Reader) *MessageReader {
+	limited := &io.LimitedReader{R: r, N: MAX_MESSAGE_SIZE}
+	return &MessageReader{
+		decoder: json.NewDecoder(limited),
+		limited: limited,
+		limit: MAX_MESSAGE_SIZE,
+	}
+}
+
+/*
+Sets the largest message that is read.
+*/
+func (mr *MessageReader) SetLimit(limit int64) {
+	mr.limit = limit
+}
+
+/*
+Blocks until the next complete message has been read.
+
+Returns ErrMessageTooLarge if the message is over the limit, or an error if 
+the stream fails or contains an invalid message.
+*/
+func (mr *MessageReader) Receive() (Message, error) {
+	var msg Message
+	mr.limited.N = mr.limit
+	err := mr.decoder.Decode(&msg)
+	if err != nil && mr.limited.N <= 0 {
+		return Message{}, ErrMessageTooLarge
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	if msg.Type == "" {
+		return Message{}, errors.New("received message without type")
+	}
+	return msg, nil
+}
@@ -0,0 +1,150 @@
+package model_test
+
+import (
+	"bytes"
+	"io"
+	"main/model"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := model.NewMessageWriter(&buffer)
+	sendErr := writer.Send(model.MSG_PLAY, 7, model.PlayPayload{
+		ValidOptions: 12,
+		Lines:        []string{"Current green apple - [Absurd]", "[11]last card"},
+	})
+	if sendErr != nil {
+		t.Log("unexpected send error:", sendErr)
+		t.FailNow()
+	}
+
+	reader := model.NewMessageReader(&buffer)
+	msg, receiveErr := reader.Receive()
+	if receiveErr != nil {
+		t.Log("unexpected receive error:", receiveErr)
+		t.FailNow()
+	}
+	if msg.Type != model.MSG_PLAY || msg.ID != 7 {
+		t.Log("unexpected envelope", msg.Type, msg.ID)
+		t.FailNow()
+	}
+	var play model.PlayPayload
+	decodeErr := msg.Decode(&play)
+	if decodeErr != nil {
+		t.Log("unexpected decode error:", decodeErr)
+		t.FailNow()
+	}
+	if play.ValidOptions != 12 || len(play.Lines) != 2 || play.Lines[1] != "[11]last card" {
+		t.Log("payload did not survive the round trip", play)
+		t.FailNow()
+	}
+}
+
+/*
+Several messages written at once must be read back one by one, even when
+the reader only gets a single byte per read.
+*/
+func TestMessageCoalescedAndSplit(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := model.NewMessageWriter(&buffer)
+	writer.Send(model.MSG_DISPLAY, 0, model.DisplayPayload{Lines: []string{"first"}})
+	writer.Send(model.MSG_DISPLAY, 0, model.DisplayPayload{Lines: []string{"second\nwith newline"}})
+	writer.Send(model.MSG_END, 0, model.EndPayload{Winner: "player one"})
+
+	reader := model.NewMessageReader(iotest.OneByteReader(&buffer))
+	expected := []string{model.MSG_DISPLAY, model.MSG_DISPLAY, model.MSG_END}
+	for i := 0; i < len(expected); i++ {
+		msg, err := reader.Receive()
+		if err != nil {
+			t.Log("unexpected receive error:", err)
+			t.FailNow()
+		}
+		if msg.Type != expected[i] {
+			t.Log("expected", expected[i], "received", msg.Type)
+			t.FailNow()
+		}
+		if i == 1 {
+			var display model.DisplayPayload
+			msg.Decode(&display)
+			if display.Lines[0] != "second\nwith newline" {
+				t.Log("embedded newline was not preserved")
+				t.FailNow()
+			}
+		}
+	}
+	_, eofErr := reader.Receive()
+	if eofErr != io.EOF {
+		t.Log("expected end of stream, got", eofErr)
+		t.FailNow()
+	}
+}
+
+func TestMessageInvalid(t *testing.T) {
+	reader := model.NewMessageReader(bytes.NewBufferString("{\"id\":3}\n"))
+	_, typeErr := reader.Receive()
+	if typeErr == nil {
+		t.Log("did not catch message without type")
+		t.FailNow()
+	}
+
+	reader = model.NewMessageReader(bytes.NewBufferString("Play\n3\n"))
+	_, formatErr := reader.Receive()
+	if formatErr == nil {
+		t.Log("did not catch message that is not JSON")
+		t.FailNow()
+	}
+
+	msg, _ := model.NewMessage(model.MSG_END, 0, nil)
+	var end model.EndPayload
+	if msg.Decode(&end) == nil {
+		t.Log("did not catch missing payload")
+		t.FailNow()
+	}
+}
+
+func TestMessageWriteTimeout(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	writer := model.NewMessageWriter(conn)
+	writer.SetTimeout(50 * time.Millisecond)
+
+	// the peer never reads, so the write can not complete
+	start := time.Now()
+	sendErr := writer.Send(model.MSG_DISPLAY, 0, model.DisplayPayload{Lines: []string{"anyone there?"}})
+	if sendErr == nil || time.Since(start) > time.Second {
+		t.Log("expected the write to time out, received", sendErr)
+		t.FailNow()
+	}
+	_, readErr := conn.Read(make([]byte, 1))
+	if readErr == nil {
+		t.Log("expected the connection to be closed after the failed write")
+		t.FailNow()
+	}
+}
+
+func TestMessageTooLarge(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := model.NewMessageWriter(&buffer)
+	large := model.DisplayPayload{Lines: []string{string(bytes.Repeat([]byte("a"), 2 * model.MAX_MESSAGE_SIZE))}}
+	writer.Send(model.MSG_DISPLAY, 0, large)
+	data := buffer.Bytes()
+
+	reader := model.NewMessageReader(bytes.NewReader(data))
+	_, sizeErr := reader.Receive()
+	if sizeErr != model.ErrMessageTooLarge {
+		t.Log("expected the message to be too large, received", sizeErr)
+		t.FailNow()
+	}
+
+	reader = model.NewMessageReader(bytes.NewReader(data))
+	reader.SetLimit(model.MAX_HOST_MESSAGE_SIZE)
+	msg, receiveErr := reader.Receive()
+	if receiveErr != nil || msg.Type != model.MSG_DISPLAY {
+		t.Log("expected the message to be read with a higher limit:", receiveErr)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+/*
+Prompts a player for one of the cards of a round, a card from the hand for 
+ACTION_PLAY_CARD or one of the submissions for ACTION_JUDGE. Players that 
+support rich cards get the round and answer with the ID of the card, the 
+others are shown the prompt lines and answer with the position as before.
+
+Returns the position of the chosen card, or the same errors as Play.
+*/
+func (n *Network) PlayCards(ctx context.Context, playerName string, round RoundPayload, prompt []string) (int, error) {
+	cards := round.Hand
+	if round.Action == ACTION_JUDGE {
+		cards = round.Submissions
+	}
+	if len(cards) == 0 {
+		return 0, errors.New("no cards to choose from")
+	}
+	payload := PlayPayload{
+		ValidOptions: len(cards),
+		Lines: prompt,
+		Round: &round,
+	}
+	return n.prompt(ctx, playerName, payload, func(choice ChoicePayload) (int, error) {
+		if choice.CardID == 0 {
+			return parseChoice(choice.Input, len(cards))
+		}
+		for i := 0; i < len(cards); i++ {
+			if cards[i].ID == choice.CardID {
+				return i, nil
+			}
+		}
+		return 0, errors.New("card " + strconv.Itoa(choice.CardID) + " is not one of the options")
+	})
+}
+
+/*
+Answers the play message with the given request ID with the ID of the 
+chosen card.
+*/
+func (n *Network) RespondCard(id int, cardID int) error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.lock.Unlock()
+	return writer.Send(MSG_CHOICE, id, ChoicePayload{CardID: cardID})
+}
+
+/*
+Returns the state of the current round for a prompt, the caller adds the 
+cards to choose from.
+*/
+func (b *Board) roundPayload(action string) RoundPayload {
+	round := RoundPayload{
+		Action: action,
+		Round: b.round,
+		Judge: b.CurrentJudgeName(),
+		GreenApple: cardPayload(b.currentGreenApple),
+	}
+	for i := 0; i < len(b.players); i++ {
+		round.Scores = append(round.Scores, ScorePayload{Name: b.players[i].PlayerName(), Score: b.players[i].Score()})
+	}
+	return round
+}
+
+func cardPayload(card Card) CardPayload {
+	return CardPayload{ID: card.id, Header: card.header, Description: card.description}
+}
+
+func cardPayloads(cards []Card) []CardPayload {
+	payloads := make([]CardPayload, len(cards))
+	for i := 0; i < len(cards); i++ {
+		payloads[i] = cardPayload(cards[i])
+	}
+	return payloads
+}
@@ -0,0 +1,172 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"testing"
+	"time"
+)
+
+/*
+Creates a board for a client that supports rich cards and three bots, with 
+a bot as the judge and a green apple on the board. The test network keeps 
+its legacy player off the board.
+*/
+func generateRichTestBoard(t *testing.T) (*model.Board, *model.MessageReader, *model.MessageWriter) {
+	network, conn, _, _ := generateTestNetwork(t)
+	t.Cleanup(func() { conn.Close() })
+	result, reader, writer := sendTestHello(t, conn.RemoteAddr().String(), model.HelloPayload{
+		Version: model.PROTOCOL_VERSION,
+		MinVersion: model.MIN_PROTOCOL_VERSION,
+		Features: []string{model.FEATURE_RICH_CARDS},
+	})
+	if !result.Accepted {
+		t.Log("expected the hello to be accepted:", result.Reason)
+		t.FailNow()
+	}
+	accepted, reason := joinTestClient(t, reader, writer, "rich player")
+	if !accepted {
+		t.Log("expected the join to be accepted:", reason)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+
+	board := newTestBoard(t,
+		*model.NewPlayer("rich player", false, false, 7),
+		*model.NewPlayer("bot one", false, true, 7),
+		*model.NewPlayer("bot two", false, true, 7),
+		*model.NewPlayer("bot three", false, true, 7))
+	board.SetNetwork(network)
+	board.DrawGreenApple()
+	for board.CurrentJudgeName() == "rich player" {
+		board.ItterateJudge()
+	}
+	return board, reader, writer
+}
+
+/*
+Reads messages on the test client until a play message arrives, which has 
+to carry the round.
+*/
+func receiveRichPlay(t *testing.T, reader *model.MessageReader) (model.Message, model.PlayPayload) {
+	msg, err := skipToPlay(reader)
+	if err != nil {
+		t.Log("unexpected receive error:", err)
+		t.FailNow()
+	}
+	var play model.PlayPayload
+	decodeErr := msg.Decode(&play)
+	if decodeErr != nil || play.Round == nil {
+		t.Log("expected the play message to carry the round:", decodeErr)
+		t.FailNow()
+	}
+	return msg, play
+}
+
+func TestChooseCardsRich(t *testing.T) {
+	board, reader, writer := generateRichTestBoard(t)
+	chosen := make(chan error, 1)
+	go func() {
+		chosen <- board.ChooseCards()
+	}()
+
+	msg, play := receiveRichPlay(t, reader)
+	round := play.Round
+	if round.Action != model.ACTION_PLAY_CARD || round.Round != 1 || round.Judge != board.CurrentJudgeName() {
+		t.Log("expected the round to describe the turn, received", round)
+		t.FailNow()
+	}
+	if round.GreenApple.ID == 0 || len(round.Hand) != 7 || len(round.Scores) != 4 || len(round.Submissions) != 0 {
+		t.Log("expected the green apple, the hand and the scores, received", round)
+		t.FailNow()
+	}
+	if len(play.Lines) == 0 {
+		t.Log("expected the prompt lines to be sent along")
+		t.FailNow()
+	}
+
+	// unknown cards are rejected
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{CardID: 999})
+	msg, play = receiveRichPlay(t, reader)
+	if play.Error == "" {
+		t.Log("expected an unknown card to be rejected")
+		t.FailNow()
+	}
+	picked := play.Round.Hand[3]
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{CardID: picked.ID})
+	if chooseErr := <-chosen; chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+
+	hand, _ := board.PlayersHand("rich player")
+	for _, card := range hand {
+		if card.ID() == picked.ID {
+			t.Log("expected the picked card to be played")
+			t.FailNow()
+		}
+	}
+	if len(hand) != 6 {
+		t.Log("expected one card to be played, hand has", len(hand))
+		t.FailNow()
+	}
+}
+
+func TestJudgeRich(t *testing.T) {
+	board, reader, writer := generateRichTestBoard(t)
+	for board.CurrentJudgeName() != "rich player" {
+		board.ItterateJudge()
+	}
+	chooseErr := board.ChooseCards()
+	if chooseErr != nil {
+		t.Log(chooseErr)
+		t.FailNow()
+	}
+	judged := make(chan int, 1)
+	go func() {
+		winner, _ := board.Judge()
+		judged <- winner
+	}()
+
+	msg, play := receiveRichPlay(t, reader)
+	if play.Round.Action != model.ACTION_JUDGE || len(play.Round.Submissions) != 3 || len(play.Round.Hand) != 7 {
+		t.Log("expected the submissions to judge, received", play.Round)
+		t.FailNow()
+	}
+	writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{CardID: play.Round.Submissions[2].ID})
+	if winner := <-judged; winner != 2 {
+		t.Log("expected the picked submission to win, received", winner)
+		t.FailNow()
+	}
+}
+
+func TestPlayCardsLegacy(t *testing.T) {
+	network, conn, reader, writer := generateTestNetwork(t)
+	defer conn.Close()
+
+	round := model.RoundPayload{
+		Action: model.ACTION_PLAY_CARD,
+		Hand: []model.CardPayload{{ID: 4, Header: "[A]"}, {ID: 9, Header: "[B]"}},
+	}
+	go func() {
+		msg, err := reader.Receive()
+		if err != nil {
+			return
+		}
+		var play model.PlayPayload
+		msg.Decode(&play)
+		if play.Round != nil {
+			// a round the client can not read is answered with an invalid choice
+			writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "none"})
+			return
+		}
+		writer.Send(model.MSG_CHOICE, msg.ID, model.ChoicePayload{Input: "1"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	choice, playErr := network.PlayCards(ctx, "online player 0", round, []string{"pick one"})
+	if playErr != nil || choice != 1 {
+		t.Log("expected the legacy client to answer by position without the round, received", choice, playErr)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"strings"
+)
+
+/*
+Adds a spectator under the name they chose. Spectators can join at any 
+time and share the names of the players, but never take a seat.
+
+Returns an error explaining why the name was rejected.
+*/
+func (n *Network) addSpectator(spectator *PlayerConnection) error {
+	n.lock.Lock()
+	nameErr := n.validateName(spectator.playerName)
+	if nameErr == nil {
+		spectator.connected = true
+		n.spectators = append(n.spectators, spectator)
+	}
+	n.lock.Unlock()
+	if nameErr != nil {
+		return nameErr
+	}
+	n.emit(NetworkEvent{Type: EVENT_SPECTATOR_JOINED, PlayerName: spectator.playerName})
+	return nil
+}
+
+/*
+Reads from a spectator until the connection fails or they leave, spectators 
+have nothing to answer so everything but chat is dropped.
+*/
+func (n *Network) spectatorLoop(spectator *PlayerConnection) {
+	defer n.removeSpectator(spectator)
+	for {
+		msg, err := spectator.reader.Receive()
+		if err != nil || msg.Type == MSG_LEAVE {
+			return
+		}
+		if msg.Type == MSG_CHAT {
+			n.relayChat(spectator, msg)
+		}
+	}
+}
+
+/*
+Removes a spectator and closes the connection.
+*/
+func (n *Network) removeSpectator(spectator *PlayerConnection) {
+	n.lock.Lock()
+	removed := false
+	for i := 0; i < len(n.spectators); i++ {
+		if n.spectators[i] == spectator {
+			n.spectators = append(n.spectators[:i], n.spectators[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	n.lock.Unlock()
+	spectator.conn.Close()
+	if removed {
+		n.emit(NetworkEvent{Type: EVENT_SPECTATOR_LEFT, PlayerName: spectator.playerName})
+	}
+}
+
+/*
+Returns the index of the named spectator, or -1. The lock must be held.
+*/
+func (n *Network) spectatorIndex(name string) int {
+	for i := 0; i < len(n.spectators); i++ {
+		if n.spectators[i].playerName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+Returns a snapshot of the spectator connections.
+*/
+func (n *Network) spectatorConnections() []*PlayerConnection {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]*PlayerConnection{}, n.spectators...)
+}
+
+/*
+Sends information to every spectator, failed connections are left for the 
+spectator loop to clean up.
+*/
+func (n *Network) SpectatorDisplay(info string) {
+	spectators := n.spectatorConnections()
+	for i := 0; i < len(spectators); i++ {
+		spectators[i].writer.Send(MSG_DISPLAY, 0, DisplayPayload{
+			Lines: strings.Split(info, "\n"),
+		})
+	}
+}
+
+/*
+Returns the names of all spectators.
+*/
+func (n *Network) ListSpectators() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	names := make([]string, len(n.spectators))
+	for i := 0; i < len(n.spectators); i++ {
+		names[i] = n.spectators[i].playerName
+	}
+	return names
+}
+
+/*
+Returns how many spectators are watching.
+*/
+func (n *Network) CountSpectators() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return len(n.spectators)
+}
+
+/*
+Joins the game as a spectator under the given name.
+
+Returns whether the host accepted the spectator and the reason if not.
+*/
+func (n *Network) Spectate(name string) (bool, string, error) {
+	result, err := n.sendJoin(JoinPayload{Name: name, Spectate: true})
+	if err != nil {
+		return false, "", err
+	}
+	if result.Accepted {
+		n.lock.Lock()
+		n.playerName = result.Name
+		n.lock.Unlock()
+	}
+	return result.Accepted, result.Reason, nil
+}
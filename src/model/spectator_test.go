@@ -0,0 +1,100 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"net"
+	"strings"
+	"testing"
+)
+
+/*
+Connects a spectator to the same host as conn.
+*/
+func joinTestSpectator(t *testing.T, network *model.Network, conn net.Conn, name string) *model.MessageReader {
+	_, reader, writer := dialTestClient(t, conn)
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: name, Spectate: true})
+	if !result.Accepted {
+		t.Log("expected the spectator to be accepted,", result.Reason)
+		t.FailNow()
+	}
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_SPECTATOR_JOINED || event.PlayerName != name {
+		t.Log("expected a spectator event, received", event)
+		t.FailNow()
+	}
+	return reader
+}
+
+func TestSpectatorJoinsRunningGame(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	network.StartGame()
+
+	reader := joinTestSpectator(t, network, conn, "watcher")
+	if network.CountSpectators() != 1 || network.CountOnlinePlayers() != 1 {
+		t.Log("expected the spectator to be listed apart from the players")
+		t.FailNow()
+	}
+	players := network.ListPlayers()
+	for i := 0; i < len(players); i++ {
+		if players[i] == "watcher" {
+			t.Log("expected the spectator not to be a player")
+			t.FailNow()
+		}
+	}
+	_, playErr := network.Play(context.Background(), "watcher", 2, []string{"pick"})
+	if playErr == nil {
+		t.Log("expected spectators to never be prompted")
+		t.FailNow()
+	}
+
+	network.MassDisplay("hello everyone")
+	msg, err := reader.Receive()
+	var display model.DisplayPayload
+	msg.Decode(&display)
+	if err != nil || msg.Type != model.MSG_DISPLAY || strings.Join(display.Lines, "") != "hello everyone" {
+		t.Log("expected spectators to receive announcements, received", msg, err)
+		t.FailNow()
+	}
+}
+
+func TestSpectatorName(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+
+	_, reader, writer := dialTestClient(t, conn)
+	result := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "online player 0", Spectate: true})
+	if result.Accepted {
+		t.Log("expected spectators to not share names with players")
+		t.FailNow()
+	}
+	result = sendTestJoin(t, reader, writer, model.JoinPayload{Name: "watcher", Spectate: true})
+	if !result.Accepted || result.Token != "" {
+		t.Log("expected the spectator to be accepted without a seat token, received", result)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+
+	_, playerReader, playerWriter := dialTestClient(t, conn)
+	accepted, _ := joinTestClient(t, playerReader, playerWriter, "watcher")
+	if accepted {
+		t.Log("expected players to not share names with spectators")
+		t.FailNow()
+	}
+}
+
+func TestSpectatorLeaves(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+	_, reader, writer := dialTestClient(t, conn)
+	sendTestJoin(t, reader, writer, model.JoinPayload{Name: "watcher", Spectate: true})
+	awaitEvent(t, network)
+
+	writer.Send(model.MSG_LEAVE, 0, nil)
+	event := awaitEvent(t, network)
+	if event.Type != model.EVENT_SPECTATOR_LEFT || network.CountSpectators() != 0 {
+		t.Log("expected the spectator to leave, received", event)
+		t.FailNow()
+	}
+}
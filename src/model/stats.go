@@ -0,0 +1,116 @@
+package model
+
+import (
+	"strconv"
+	"sync"
+)
+
+/*
+Counts what every player did during a game, from the events of the board.
+Subscribe Record to collect, see Board.Subscribe.
+*/
+type GameStats struct {
+	lock sync.Mutex
+	rounds int
+	players []string
+	submitted map[string]int
+	won map[string]int
+	disconnects map[string]int
+	winner string
+}
+
+/*
+Creates and returns empty statistics.
+*/
+func NewGameStats() *GameStats {
+	return &GameStats{
+		submitted: make(map[string]int),
+		won: make(map[string]int),
+		disconnects: make(map[string]int),
+	}
+}
+
+/*
+Counts an event, safe for concurrent use.
+*/
+func (s *GameStats) Record(event GameEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch event.Type {
+	case GAME_PLAYER_JOINED:
+		s.players = append(s.players, event.PlayerName)
+	case GAME_ROUND_STARTED:
+		s.rounds++
+	case GAME_CARD_SUBMITTED:
+		s.submitted[event.PlayerName]++
+	case GAME_ROUND_WON:
+		s.won[event.PlayerName]++
+	case GAME_PLAYER_DISCONNECTED:
+		s.disconnects[event.PlayerName]++
+	case GAME_WON:
+		s.winner = event.PlayerName
+	}
+}
+
+/*
+Returns how many rounds were played.
+*/
+func (s *GameStats) Rounds() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rounds
+}
+
+/*
+Returns how many cards the player submitted.
+*/
+func (s *GameStats) Submitted(playerName string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.submitted[playerName]
+}
+
+/*
+Returns how many rounds the player won.
+*/
+func (s *GameStats) RoundsWon(playerName string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.won[playerName]
+}
+
+/*
+Returns how many times the player lost the connection.
+*/
+func (s *GameStats) Disconnects(playerName string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.disconnects[playerName]
+}
+
+/*
+Returns the winner of the game, or an empty string while the game goes on.
+*/
+func (s *GameStats) Winner() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.winner
+}
+
+/*
+Returns one line for the game and one for every player, in the order they
+joined.
+*/
+func (s *GameStats) Summary() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	summary := []string{strconv.Itoa(s.rounds) + " rounds played"}
+	for _, name := range s.players {
+		line := name + ": won " + strconv.Itoa(s.won[name]) + " rounds, submitted " + strconv.Itoa(s.submitted[name]) + " cards"
+		if s.disconnects[name] > 0 {
+			line += ", lost the connection " + strconv.Itoa(s.disconnects[name]) + " times"
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+How long a generated self-signed certificate is valid.
+*/
+const SELF_SIGNED_VALIDITY = 365 * 24 * time.Hour
+
+/*
+Creates a self-signed certificate for hosting a game over TLS, valid for
+localhost and the given host names or addresses. Players can not verify it
+against a certificate authority, they pin its fingerprint instead.
+
+Returns an error if the key or the certificate can not be generated.
+*/
+func GenerateCertificate(hosts ...string) (tls.Certificate, error) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		return tls.Certificate{}, keyErr
+	}
+	serial, serialErr := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if serialErr != nil {
+		return tls.Certificate{}, serialErr
+	}
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: "Apples2Apples host"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(SELF_SIGNED_VALIDITY),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames: []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, host := range hosts {
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, certErr := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if certErr != nil {
+		return tls.Certificate{}, certErr
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey: key,
+	}, nil
+}
+
+/*
+Loads a certificate and its private key from PEM files.
+*/
+func LoadCertificate(certFile string, keyFile string) (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+/*
+Returns the SHA-256 fingerprint of the certificate as colon separated hex,
+the form shown to the host and entered by players to pin it.
+*/
+func Fingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	return formatFingerprint(cert.Certificate[0])
+}
+
+/*
+Hashes a DER encoded certificate into its fingerprint.
+*/
+func formatFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i := 0; i < len(sum); i++ {
+		parts[i] = strings.ToUpper(hex.EncodeToString(sum[i : i+1]))
+	}
+	return strings.Join(parts, ":")
+}
+
+/*
+Brings a fingerprint to a comparable form, so that players may enter it
+with or without colons and in either case.
+*/
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.ReplaceAll(fingerprint, ":", "")
+	fingerprint = strings.ReplaceAll(fingerprint, " ", "")
+	return strings.ToLower(fingerprint)
+}
+
+/*
+Returns the TLS settings for a host serving the given certificate.
+*/
+func ServerTLSConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+/*
+Returns the TLS settings for a joining client. With a pinned fingerprint
+only the host certificate with that fingerprint is accepted, which is how
+self-signed certificates are trusted. Without a pin the host certificate
+must be signed by a certificate authority the system trusts.
+*/
+func ClientTLSConfig(pin string) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if pin == "" {
+		return config
+	}
+	want := normalizeFingerprint(pin)
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("host sent no certificate")
+		}
+		got := formatFingerprint(rawCerts[0])
+		if normalizeFingerprint(got) != want {
+			return errors.New("host certificate fingerprint " + got + " does not match the pinned fingerprint")
+		}
+		return nil
+	}
+	return config
+}
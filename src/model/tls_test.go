@@ -0,0 +1,106 @@
+package model_test
+
+import (
+	"crypto/tls"
+	"main/model"
+	"strings"
+	"testing"
+)
+
+/*
+Creates a self-signed certificate for the tests.
+*/
+func generateTestCertificate(t *testing.T) tls.Certificate {
+	cert, certErr := model.GenerateCertificate("127.0.0.1")
+	if certErr != nil {
+		t.Log("could not generate a certificate:", certErr)
+		t.FailNow()
+	}
+	return cert
+}
+
+func TestTLSTransportRoundTrip(t *testing.T) {
+	cert := generateTestCertificate(t)
+	pin := model.Fingerprint(cert)
+
+	host := model.NewTCPTransport()
+	host.SetTLS(model.ServerTLSConfig(cert))
+	client := model.NewTCPTransport()
+	client.SetTLS(model.ClientTLSConfig(pin))
+	roundTripBetween(t, host, client, []byte("Play\nsome prompt"))
+
+	secureHost := model.NewWebSocketTransport("")
+	secureHost.SetTLS(model.ServerTLSConfig(cert))
+	secureClient := model.NewWebSocketTransport("")
+	secureClient.SetTLS(model.ClientTLSConfig(strings.ToLower(strings.ReplaceAll(pin, ":", ""))))
+	roundTripBetween(t, secureHost, secureClient, []byte("short"))
+}
+
+func TestTLSRejectsWrongPin(t *testing.T) {
+	cert := generateTestCertificate(t)
+	other := generateTestCertificate(t)
+	if model.Fingerprint(cert) == model.Fingerprint(other) {
+		t.Log("expected different certificates to have different fingerprints")
+		t.FailNow()
+	}
+
+	pairs := [][2]model.Transport{
+		{model.NewTCPTransport(), model.NewTCPTransport()},
+		{model.NewWebSocketTransport(""), model.NewWebSocketTransport("")},
+	}
+	for _, pair := range pairs {
+		host, client := pair[0], pair[1]
+		host.SetTLS(model.ServerTLSConfig(cert))
+		listen, listenErr := host.Listen("127.0.0.1:0")
+		if listenErr != nil {
+			t.Log("could not listen:", listenErr)
+			t.FailNow()
+		}
+		defer listen.Close()
+		go func() {
+			for {
+				conn, err := listen.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					conn.Read(make([]byte, 1))
+					conn.Close()
+				}()
+			}
+		}()
+
+		client.SetTLS(model.ClientTLSConfig(model.Fingerprint(other)))
+		_, pinErr := client.Dial(listen.Addr().String())
+		if pinErr == nil {
+			t.Log("expected", host.Name(), "to be rejected with the wrong pin")
+			t.FailNow()
+		}
+		client.SetTLS(model.ClientTLSConfig(""))
+		_, verifyErr := client.Dial(listen.Addr().String())
+		if verifyErr == nil {
+			t.Log("expected a self-signed certificate to fail verification without a pin")
+			t.FailNow()
+		}
+	}
+}
+
+func TestTLSWithTLSCopies(t *testing.T) {
+	cert := generateTestCertificate(t)
+	hostConfig := model.ClientTLSConfig(model.Fingerprint(cert))
+	backupConfig := model.ClientTLSConfig("")
+
+	transports := []model.Transport{model.NewTCPTransport(), model.NewWebSocketTransport("/game")}
+	for _, transport := range transports {
+		transport.SetTLS(hostConfig)
+		backup := transport.WithTLS(backupConfig)
+		if transport.TLS() != hostConfig || backup.TLS() != backupConfig {
+			t.Log("expected", transport.Name(), "to keep its TLS settings when copied")
+			t.FailNow()
+		}
+		if webSocket, ok := backup.(*model.WebSocketTransport); ok && webSocket.Path() != "/game" {
+			t.Log("expected the copy to keep the path, received", webSocket.Path())
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+/*
+A transport decides how the host listens for players and how a joining
+client reaches the host. Both sides of a game must use the same transport.
+*/
+type Transport interface {
+	Listen(address string) (net.Listener, error)
+	Dial(address string) (net.Conn, error)
+	Name() string
+	SetTLS(config *tls.Config)
+	TLS() *tls.Config
+	WithTLS(config *tls.Config) Transport
+}
+
+/*
+How long dialing may take, including the TLS handshake. A listener that
+does not accept yet, like that of a backup host, would otherwise hold the
+TLS handshake up for good.
+*/
+const DIAL_TIMEOUT = 10 * time.Second
+
+/*
+Plain TCP transport, the original way of connecting players.
+*/
+type TCPTransport struct {
+	tls *tls.Config
+}
+
+/*
+Creates and returns a new raw TCP transport.
+*/
+func NewTCPTransport() *TCPTransport {
+	return &TCPTransport{}
+}
+
+/*
+Opens a TCP listener on the given address, serving TLS if it is set.
+*/
+func (t *TCPTransport) Listen(address string) (net.Listener, error) {
+	if t.tls != nil {
+		return tls.Listen(CONN_TYPE, address, t.tls)
+	}
+	return net.Listen(CONN_TYPE, address)
+}
+
+/*
+Dials the host at the given address over TCP, with the TLS handshake done
+before returning if it is set.
+*/
+func (t *TCPTransport) Dial(address string) (net.Conn, error) {
+	if t.tls != nil {
+		return tls.DialWithDialer(&net.Dialer{Timeout: DIAL_TIMEOUT}, CONN_TYPE, address, t.tls)
+	}
+	return net.DialTimeout(CONN_TYPE, address, DIAL_TIMEOUT)
+}
+
+func (t *TCPTransport) Name() string {
+	if t.tls != nil {
+		return "tcp with tls"
+	}
+	return "tcp"
+}
+
+/*
+Secures the transport with TLS, see ServerTLSConfig and ClientTLSConfig.
+A nil config goes back to plaintext.
+*/
+func (t *TCPTransport) SetTLS(config *tls.Config) {
+	t.tls = config
+}
+
+/*
+Returns the TLS settings in use, or nil for plaintext.
+*/
+func (t *TCPTransport) TLS() *tls.Config {
+	return t.tls
+}
+
+/*
+Returns a copy of the transport that uses the given TLS settings, the
+transport itself is left as it is.
+*/
+func (t *TCPTransport) WithTLS(config *tls.Config) Transport {
+	return &TCPTransport{tls: config}
+}
+
+/*
+WebSocket transport, the host serves a HTTP endpoint on path and upgrades
+incomming requests to WebSocket connections.
+*/
+type WebSocketTransport struct {
+	path string
+	tls  *tls.Config
+}
+
+/*
+Creates and returns a new WebSocket transport serving on the given path.
+If the path is empty the default path is used.
+*/
+func NewWebSocketTransport(path string) *WebSocketTransport {
+	if path == "" {
+		path = WS_PATH
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return &WebSocketTransport{
+		path: path,
+	}
+}
+
+/*
+Returns the HTTP path the WebSocket endpoint is served on.
+*/
+func (t *WebSocketTransport) Path() string {
+	return t.path
+}
+
+/*
+Starts a HTTP server on the given address, over TLS if it is set, and 
+returns a listener that produces upgraded WebSocket connections.
+*/
+func (t *WebSocketTransport) Listen(address string) (net.Listener, error) {
+	listen, err := listenWebSocket(address, t.path, t.tls)
+	if err != nil {
+		return nil, err
+	}
+	return listen, nil
+}
+
+/*
+Dials the host at the given address and performs the WebSocket handshake.
+*/
+func (t *WebSocketTransport) Dial(address string) (net.Conn, error) {
+	return dialWebSocket(address, t.path, t.tls)
+}
+
+func (t *WebSocketTransport) Name() string {
+	if t.tls != nil {
+		return "secure websocket"
+	}
+	return "websocket"
+}
+
+/*
+Serves and dials the WebSocket endpoint over TLS (wss), see
+ServerTLSConfig and ClientTLSConfig. A nil config goes back to plaintext.
+*/
+func (t *WebSocketTransport) SetTLS(config *tls.Config) {
+	t.tls = config
+}
+
+/*
+Returns the TLS settings in use, or nil for plaintext.
+*/
+func (t *WebSocketTransport) TLS() *tls.Config {
+	return t.tls
+}
+
+/*
+Returns a copy of the transport that uses the given TLS settings, the
+transport itself is left as it is.
+*/
+func (t *WebSocketTransport) WithTLS(config *tls.Config) Transport {
+	return &WebSocketTransport{path: t.path, tls: config}
+}
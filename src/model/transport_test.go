@@ -0,0 +1,215 @@
+package model_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"main/model"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+/*
+Sends data through the transport in both directions and verifies that it
+arrives intact.
+*/
+func roundTrip(t *testing.T, transport model.Transport, payload []byte) {
+	roundTripBetween(t, transport, transport, payload)
+}
+
+/*
+Same as roundTrip, with the host and the client using their own transport.
+*/
+func roundTripBetween(t *testing.T, transport model.Transport, client model.Transport, payload []byte) {
+	listen, listenErr := transport.Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+	defer listen.Close()
+
+	accepted := make(chan []byte)
+	go func() {
+		conn, err := listen.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		defer conn.Close()
+		received := make([]byte, len(payload))
+		_, readErr := io.ReadFull(conn, received)
+		if readErr != nil {
+			accepted <- nil
+			return
+		}
+		conn.Write(received)
+		accepted <- received
+	}()
+
+	conn, dialErr := client.Dial(listen.Addr().String())
+	if dialErr != nil {
+		t.Log("could not dial:", dialErr)
+		t.FailNow()
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, writeErr := conn.Write(payload)
+	if writeErr != nil {
+		t.Log("could not write:", writeErr)
+		t.FailNow()
+	}
+	echo := make([]byte, len(payload))
+	_, readErr := io.ReadFull(conn, echo)
+	if readErr != nil {
+		t.Log("could not read echo:", readErr)
+		t.FailNow()
+	}
+	if !bytes.Equal(<-accepted, payload) {
+		t.Log(transport.Name(), "host received corrupted payload")
+		t.FailNow()
+	}
+	if !bytes.Equal(echo, payload) {
+		t.Log(transport.Name(), "client received corrupted payload")
+		t.FailNow()
+	}
+}
+
+func TestTCPTransportRoundTrip(t *testing.T) {
+	roundTrip(t, model.NewTCPTransport(), []byte("Play\nsome prompt"))
+}
+
+func TestWebSocketTransportRoundTrip(t *testing.T) {
+	transport := model.NewWebSocketTransport("")
+	roundTrip(t, transport, []byte("short"))
+	roundTrip(t, transport, bytes.Repeat([]byte("medium "), 100))
+	roundTrip(t, transport, bytes.Repeat([]byte("large payload "), 10_000))
+}
+
+func TestWebSocketPath(t *testing.T) {
+	if model.NewWebSocketTransport("").Path() != model.WS_PATH {
+		t.Log("expected the default path")
+		t.FailNow()
+	}
+	if model.NewWebSocketTransport("game").Path() != "/game" {
+		t.Log("expected a leading slash to be added")
+		t.FailNow()
+	}
+}
+
+func TestWebSocketRejectsPlainHTTP(t *testing.T) {
+	transport := model.NewWebSocketTransport("/game")
+	listen, listenErr := transport.Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+	defer listen.Close()
+
+	response, getErr := http.Get("http://" + listen.Addr().String() + "/game")
+	if getErr != nil {
+		t.Log("unexpected http error:", getErr)
+		t.FailNow()
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Log("expected a plain http request to be rejected, got", response.Status)
+		t.FailNow()
+	}
+
+	wrongPath := model.NewWebSocketTransport("/other")
+	_, dialErr := wrongPath.Dial(listen.Addr().String())
+	if dialErr == nil {
+		t.Log("expected the upgrade on an unknown path to fail")
+		t.FailNow()
+	}
+}
+
+/*
+Upgrades a plain TCP connection to the WebSocket listener by hand, so the
+test can write frames byte by byte. Returns the client connection and the
+connection accepted by the listener.
+*/
+func rawWebSocket(t *testing.T, listen net.Listener) (net.Conn, *bufio.Reader, net.Conn) {
+	conn, dialErr := net.Dial("tcp", listen.Addr().String())
+	if dialErr != nil {
+		t.Log("could not dial:", dialErr)
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET " + model.WS_PATH + " HTTP/1.1\r\n" +
+		"Host: " + listen.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	response, responseErr := http.ReadResponse(reader, nil)
+	if responseErr != nil || response.StatusCode != http.StatusSwitchingProtocols {
+		t.Log("expected the upgrade to be accepted:", responseErr)
+		t.FailNow()
+	}
+	accepted, acceptErr := listen.Accept()
+	if acceptErr != nil {
+		t.Log("could not accept:", acceptErr)
+		t.FailNow()
+	}
+	t.Cleanup(func() { accepted.Close() })
+	return conn, reader, accepted
+}
+
+func TestWebSocketPartialHeader(t *testing.T) {
+	listen, listenErr := model.NewWebSocketTransport("").Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+	defer listen.Close()
+	conn, _, accepted := rawWebSocket(t, listen)
+
+	// a masked binary frame with a two byte payload and a zero mask
+	frame := []byte{0x82, 0x82, 0, 0, 0, 0, 'h', 'i'}
+	conn.Write(frame[:3])
+	accepted.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	buffer := make([]byte, 2)
+	_, timeoutErr := accepted.Read(buffer)
+	if timeoutErr == nil {
+		t.Log("expected the read to time out on the partial header")
+		t.FailNow()
+	}
+
+	conn.Write(frame[3:])
+	accepted.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, readErr := io.ReadFull(accepted, buffer)
+	if readErr != nil || string(buffer) != "hi" {
+		t.Log("expected the frame to be read once complete, received", string(buffer), readErr)
+		t.FailNow()
+	}
+}
+
+func TestWebSocketUnmaskedClientFrame(t *testing.T) {
+	listen, listenErr := model.NewWebSocketTransport("").Listen("127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+	defer listen.Close()
+	conn, reader, accepted := rawWebSocket(t, listen)
+
+	conn.Write([]byte{0x82, 0x02, 'h', 'i'})
+	accepted.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, readErr := accepted.Read(make([]byte, 2))
+	if readErr == nil {
+		t.Log("expected the unmasked frame to be rejected")
+		t.FailNow()
+	}
+	closeFrame := make([]byte, 4)
+	_, closeErr := io.ReadFull(reader, closeFrame)
+	if closeErr != nil || !bytes.Equal(closeFrame, []byte{0x88, 0x02, 0x03, 0xEA}) {
+		t.Log("expected a close frame with a protocol error, received", closeFrame, closeErr)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+)
+
+/*
+Newest and oldest protocol version this build speaks. Version 1 is the
+protocol from before the hello, its clients join straight away.
+*/
+const (
+	PROTOCOL_VERSION = 2
+	MIN_PROTOCOL_VERSION = 1
+)
+
+/*
+Optional features that host and client agree on in the hello, a feature is
+only used if both sides support it.
+*/
+const (
+	FEATURE_CHAT = "chat"
+	FEATURE_SPECTATE = "spectate"
+	FEATURE_TIMERS = "timers"
+	FEATURE_RICH_CARDS = "rich_cards"
+)
+
+/*
+Features this build supports.
+*/
+var SUPPORTED_FEATURES = []string{FEATURE_CHAT, FEATURE_SPECTATE, FEATURE_TIMERS, FEATURE_RICH_CARDS}
+
+/*
+Features of the builds from before the hello, assumed for a client that
+joins without one and for a host that does not answer the hello.
+*/
+var LEGACY_FEATURES = []string{FEATURE_CHAT, FEATURE_SPECTATE, FEATURE_TIMERS}
+
+/*
+Returns the hello this build sends.
+*/
+func newHello() HelloPayload {
+	return HelloPayload{
+		Version: PROTOCOL_VERSION,
+		MinVersion: MIN_PROTOCOL_VERSION,
+		Features: SUPPORTED_FEATURES,
+	}
+}
+
+/*
+Decides on the protocol version and the features to use with a client.
+
+Returns an error explaining which side has to be updated if there is no
+version both sides speak.
+*/
+func negotiate(hello HelloPayload) (HelloResultPayload, error) {
+	supported := "this host speaks protocol versions " + strconv.Itoa(MIN_PROTOCOL_VERSION) + " to " + strconv.Itoa(PROTOCOL_VERSION)
+	if hello.Version < MIN_PROTOCOL_VERSION {
+		return HelloResultPayload{}, errors.New("your game is too old to join, " + supported + " and yours speaks up to " + strconv.Itoa(hello.Version) + ", please update")
+	}
+	if hello.MinVersion > PROTOCOL_VERSION {
+		return HelloResultPayload{}, errors.New("the host is too old for your game, " + supported + " and yours needs at least " + strconv.Itoa(hello.MinVersion) + ", ask the host to update")
+	}
+	version := hello.Version
+	if version > PROTOCOL_VERSION {
+		version = PROTOCOL_VERSION
+	}
+	features := []string{}
+	for _, feature := range hello.Features {
+		if hasFeature(SUPPORTED_FEATURES, feature) && !hasFeature(features, feature) {
+			features = append(features, feature)
+		}
+	}
+	return HelloResultPayload{Accepted: true, Version: version, Features: features}, nil
+}
+
+/*
+Returns true if the feature is in the list.
+*/
+func hasFeature(features []string, feature string) bool {
+	for _, supported := range features {
+		if supported == feature {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Answers the hello of a connection and remembers what was agreed on, a
+connection that sends no hello keeps the legacy features.
+
+Returns an error if the client can not play with this host, the reason has
+been sent to the client.
+*/
+func answerHello(player *PlayerConnection, msg Message) error {
+	var hello HelloPayload
+	decodeErr := msg.Decode(&hello)
+	if decodeErr != nil {
+		player.writer.Send(MSG_HELLO_RESULT, msg.ID, HelloResultPayload{Reason: "invalid hello message"})
+		return decodeErr
+	}
+	result, negotiateErr := negotiate(hello)
+	if negotiateErr != nil {
+		player.writer.Send(MSG_HELLO_RESULT, msg.ID, HelloResultPayload{Reason: negotiateErr.Error()})
+		return negotiateErr
+	}
+	player.version = result.Version
+	player.features = result.Features
+	return player.writer.Send(MSG_HELLO_RESULT, msg.ID, result)
+}
+
+/*
+Returns true if the player agreed on the feature in the hello.
+*/
+func (p *PlayerConnection) supports(feature string) bool {
+	return hasFeature(p.features, feature)
+}
+
+/*
+Sends the hello of this build to the host without waiting for the answer,
+hosts from before the hello ignore it. The answer is picked up by the next
+request, see request.
+*/
+func (n *Network) sendHello() error {
+	n.lock.Lock()
+	writer := n.hostWriter
+	n.hostVersion = MIN_PROTOCOL_VERSION
+	n.hostFeatures = LEGACY_FEATURES
+	n.lock.Unlock()
+	return writer.Send(MSG_HELLO, 0, newHello())
+}
+
+/*
+Remembers the version and features agreed on with the host.
+
+Returns an error with the reason if the host can not play with this client.
+*/
+func (n *Network) helloResult(msg Message) error {
+	var result HelloResultPayload
+	decodeErr := msg.Decode(&result)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if !result.Accepted {
+		return errors.New(result.Reason)
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.hostVersion = result.Version
+	n.hostFeatures = result.Features
+	return nil
+}
+
+/*
+Returns the protocol version agreed on with the host.
+*/
+func (n *Network) HostVersion() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.hostVersion
+}
+
+/*
+Returns true if both this client and the host support the feature. Until
+the host answered the hello the legacy features are assumed.
+*/
+func (n *Network) HostSupports(feature string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return hasFeature(n.hostFeatures, feature)
+}
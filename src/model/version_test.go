@@ -0,0 +1,137 @@
+package model_test
+
+import (
+	"context"
+	"main/model"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Dials a second client to the test network and sends the given hello.
+
+Returns the answer of the host along with the connection.
+*/
+func sendTestHello(t *testing.T, address string, hello model.HelloPayload) (model.HelloResultPayload, *model.MessageReader, *model.MessageWriter) {
+	conn, dialErr := model.NewTCPTransport().Dial(address)
+	if dialErr != nil {
+		t.Log("incorrect test config,", dialErr)
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader, writer := model.NewMessageReader(conn), model.NewMessageWriter(conn)
+	writer.Send(model.MSG_HELLO, 0, hello)
+	msg, err := reader.Receive()
+	if err != nil || msg.Type != model.MSG_HELLO_RESULT {
+		t.Log("expected a hello result, received", msg.Type, err)
+		t.FailNow()
+	}
+	var result model.HelloResultPayload
+	msg.Decode(&result)
+	return result, reader, writer
+}
+
+func TestHelloNegotiation(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+
+	// a newer client without spectating or timers and with a feature unknown to the host
+	result, reader, writer := sendTestHello(t, conn.RemoteAddr().String(), model.HelloPayload{
+		Version: model.PROTOCOL_VERSION + 3,
+		MinVersion: model.MIN_PROTOCOL_VERSION,
+		Features: []string{model.FEATURE_CHAT, "teleport"},
+	})
+	if !result.Accepted || result.Version != model.PROTOCOL_VERSION {
+		t.Log("expected the newest version of the host to be used, received", result)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(result.Features, []string{model.FEATURE_CHAT}) {
+		t.Log("expected only the shared features, received", result.Features)
+		t.FailNow()
+	}
+
+	spectate := sendTestJoin(t, reader, writer, model.JoinPayload{Name: "watcher", Spectate: true})
+	if spectate.Accepted {
+		t.Log("expected spectating to be refused without the feature")
+		t.FailNow()
+	}
+	accepted, reason := joinTestClient(t, reader, writer, "online player 1")
+	if !accepted {
+		t.Log("expected the join to be accepted:", reason)
+		t.FailNow()
+	}
+	awaitEvent(t, network)
+
+	// without timers the prompt is not cancelled on the client
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, playErr := network.Play(ctx, "online player 1", 3, []string{"pick one"})
+	if playErr != model.ErrTimeout {
+		t.Log("expected a timeout, received", playErr)
+		t.FailNow()
+	}
+	receivePlay(t, reader)
+	network.Display("online player 1", "next")
+	msg, err := reader.Receive()
+	if err != nil || msg.Type != model.MSG_DISPLAY {
+		t.Log("expected no cancel to be sent, received", msg.Type, err)
+		t.FailNow()
+	}
+}
+
+func TestHelloRejected(t *testing.T) {
+	network, conn, _, _ := generateTestNetwork(t)
+	defer conn.Close()
+
+	hellos := []model.HelloPayload{
+		{Version: 0},
+		{Version: 99, MinVersion: 99},
+	}
+	for _, hello := range hellos {
+		result, reader, _ := sendTestHello(t, conn.RemoteAddr().String(), hello)
+		if result.Accepted || !strings.Contains(result.Reason, "update") {
+			t.Log("expected the hello to be rejected with a reason, received", result)
+			t.FailNow()
+		}
+		event := awaitEvent(t, network)
+		if event.Type != model.EVENT_JOIN_REFUSED {
+			t.Log("expected the host to be told about the refusal, received", event)
+			t.FailNow()
+		}
+		_, err := reader.Receive()
+		if err == nil {
+			t.Log("expected the connection to be closed")
+			t.FailNow()
+		}
+	}
+}
+
+func TestHostVersion(t *testing.T) {
+	host := new(model.Network)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	address, listenErr := host.Listener(ctx, "127.0.0.1:0")
+	if listenErr != nil {
+		t.Log("could not listen:", listenErr)
+		t.FailNow()
+	}
+
+	client := new(model.Network)
+	dialErr := client.DialHost(address.String())
+	if dialErr != nil {
+		t.Log("unexpected dial error:", dialErr)
+		t.FailNow()
+	}
+	accepted, reason, joinErr := client.Join("alice")
+	if joinErr != nil || !accepted {
+		t.Log("expected the join to be accepted:", reason, joinErr)
+		t.FailNow()
+	}
+	if client.HostVersion() != model.PROTOCOL_VERSION || !client.HostSupports(model.FEATURE_CHAT) {
+		t.Log("expected the hello to be answered, version", client.HostVersion())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,487 @@
+package model
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WS_PATH = "/apples"
+	WS_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseProtocolError = 1002
+)
+
+/*
+A WebSocket connection that implements net.Conn, every Write is sent as a
+single binary frame and Read returns the payload of incomming data frames.
+Control frames are handled internally.
+*/
+type wsConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	client    bool
+	writeLock sync.Mutex
+	remaining uint64
+	mask      [4]byte
+	masked    bool
+	maskPos   int
+	closed    bool
+}
+
+/*
+Listener that hands out upgraded WebSocket connections from a HTTP server.
+*/
+type wsListener struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan net.Conn
+	done     chan struct{}
+	once     sync.Once
+}
+
+/*
+Creates the Sec-WebSocket-Accept value for a given Sec-WebSocket-Key.
+*/
+func wsAcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + WS_GUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+/*
+Checks if a comma separated header contains the given token.
+*/
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+Starts a HTTP server on address that upgrades requests on path, serving
+HTTPS instead if a TLS config is given.
+
+Returns an error if the address can not be listened on.
+*/
+func listenWebSocket(address string, path string, config *tls.Config) (*wsListener, error) {
+	listen, err := net.Listen(CONN_TYPE, address)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		listen = tls.NewListener(listen, config)
+	}
+	wl := &wsListener{
+		listener: listen,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, wl.upgrade)
+	wl.server = &http.Server{Handler: mux, ReadHeaderTimeout: HANDSHAKE_TIMEOUT}
+	go wl.server.Serve(listen)
+	return wl, nil
+}
+
+/*
+Upgrades a HTTP request to a WebSocket connection and queues it for Accept.
+*/
+func (wl *wsListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected websocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	if _, err := buffer.WriteString(response); err != nil {
+		conn.Close()
+		return
+	}
+	if err := buffer.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+	ws := &wsConn{
+		conn:   conn,
+		reader: buffer.Reader,
+		client: false,
+	}
+	select {
+	case wl.conns <- ws:
+	case <-wl.done:
+		ws.Close()
+	}
+}
+
+/*
+Waits for and returns the next upgraded connection.
+
+Returns an error once the listener has been closed.
+*/
+func (wl *wsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-wl.conns:
+		return conn, nil
+	case <-wl.done:
+		return nil, net.ErrClosed
+	}
+}
+
+/*
+Stops the HTTP server, connections that are already upgraded stay open.
+*/
+func (wl *wsListener) Close() error {
+	var err error
+	wl.once.Do(func() {
+		close(wl.done)
+		err = wl.server.Close()
+	})
+	return err
+}
+
+func (wl *wsListener) Addr() net.Addr {
+	return wl.listener.Addr()
+}
+
+/*
+Dials address and performs the client side of the WebSocket handshake,
+after a TLS handshake if a TLS config is given.
+
+Returns an error if the host does not accept the upgrade.
+*/
+func dialWebSocket(address string, path string, config *tls.Config) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: DIAL_TIMEOUT}, CONN_TYPE, address, config)
+	} else {
+		conn, err = net.DialTimeout(CONN_TYPE, address, DIAL_TIMEOUT)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws, err := wsClientHandshake(conn, address, path)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+/*
+Sends the upgrade request over an established connection and validates the
+response. A host that does not answer within DIAL_TIMEOUT is given up on.
+*/
+func wsClientHandshake(conn net.Conn, address string, path string) (*wsConn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + address + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	conn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, errors.New("websocket upgrade refused: " + response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, errors.New("invalid websocket accept key")
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{
+		conn:   conn,
+		reader: reader,
+		client: true,
+	}, nil
+}
+
+/*
+Reads the header of the next frame. The header is only consumed once all 
+of it has arrived, along with the payload of control frames, so a read 
+that fails part way, such as on a read deadline, can be retried without 
+losing bytes of the stream.
+
+Returns an error if the frame breaks the protocol, after closing the 
+connection with a protocol error.
+*/
+func (ws *wsConn) readFrameHeader() (byte, bool, error) {
+	start, err := ws.reader.Peek(2)
+	if err != nil {
+		return 0, false, err
+	}
+	final := start[0]&0x80 != 0
+	opcode := start[0] & 0x0F
+	masked := start[1]&0x80 != 0
+	length := uint64(start[1] & 0x7F)
+	if !ws.client && !masked {
+		return 0, false, ws.fail("websocket client frames must be masked")
+	}
+	if ws.client && masked {
+		return 0, false, ws.fail("websocket server frames must not be masked")
+	}
+	size := 2
+	switch length {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if masked {
+		size += 4
+	}
+	control := opcode&0x8 != 0
+	if control && length > 125 {
+		return 0, false, ws.fail("websocket control frame too large")
+	}
+	peek := size
+	if control {
+		peek += int(length)
+	}
+	header, err := ws.reader.Peek(peek)
+	if err != nil {
+		return 0, false, err
+	}
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:10])
+	}
+	if masked {
+		copy(ws.mask[:], header[size-4:size])
+	}
+	ws.reader.Discard(size)
+	ws.masked = masked
+	ws.remaining = length
+	ws.maskPos = 0
+	return opcode, final, nil
+}
+
+/*
+Closes the connection with a protocol error, as RFC 6455 asks of endpoints 
+that receive an invalid frame.
+
+Returns an error with the reason.
+*/
+func (ws *wsConn) fail(reason string) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, wsCloseProtocolError)
+	ws.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	ws.writeFrame(wsOpClose, payload)
+	ws.conn.Close()
+	ws.closed = true
+	return errors.New(reason)
+}
+
+/*
+Reads the rest of the current frame, used for control frames whose payload 
+is already buffered by readFrameHeader.
+*/
+func (ws *wsConn) readControlPayload() ([]byte, error) {
+	payload := make([]byte, ws.remaining)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return nil, err
+	}
+	ws.unmask(payload)
+	ws.remaining = 0
+	return payload, nil
+}
+
+func (ws *wsConn) unmask(payload []byte) {
+	if !ws.masked {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= ws.mask[ws.maskPos%4]
+		ws.maskPos++
+	}
+}
+
+/*
+Reads data frame payload into p, control frames are answered or consumed
+while waiting for data.
+
+Returns io.EOF when the peer closes the connection.
+*/
+func (ws *wsConn) Read(p []byte) (int, error) {
+	for ws.remaining == 0 {
+		if ws.closed {
+			return 0, io.EOF
+		}
+		opcode, _, err := ws.readFrameHeader()
+		if err != nil {
+			return 0, err
+		}
+		switch opcode {
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			continue
+		case wsOpPing:
+			payload, err := ws.readControlPayload()
+			if err != nil {
+				return 0, err
+			}
+			if err := ws.writeFrame(wsOpPong, payload); err != nil {
+				return 0, err
+			}
+		case wsOpPong:
+			if _, err := ws.readControlPayload(); err != nil {
+				return 0, err
+			}
+		case wsOpClose:
+			payload, err := ws.readControlPayload()
+			if err != nil {
+				return 0, err
+			}
+			ws.writeFrame(wsOpClose, payload)
+			ws.closed = true
+			return 0, io.EOF
+		default:
+			return 0, ws.fail("unknown websocket opcode")
+		}
+	}
+	if uint64(len(p)) > ws.remaining {
+		p = p[:ws.remaining]
+	}
+	n, err := ws.reader.Read(p)
+	ws.unmask(p[:n])
+	ws.remaining -= uint64(n)
+	return n, err
+}
+
+/*
+Writes a single frame, client frames are masked as required by RFC 6455.
+*/
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	var maskBit byte = 0
+	if ws.client {
+		maskBit = 0x80
+	}
+	length := len(payload)
+	switch {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	data := payload
+	if ws.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		data = make([]byte, length)
+		for i := range payload {
+			data[i] = payload[i] ^ mask[i%4]
+		}
+	}
+	frame = append(frame, data...)
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+/*
+Sends p as one binary frame.
+*/
+func (ws *wsConn) Write(p []byte) (int, error) {
+	if err := ws.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+/*
+Sends a close frame and closes the underlying connection.
+*/
+func (ws *wsConn) Close() error {
+	ws.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	ws.writeFrame(wsOpClose, []byte{0x03, 0xE8})
+	return ws.conn.Close()
+}
+
+func (ws *wsConn) LocalAddr() net.Addr {
+	return ws.conn.LocalAddr()
+}
+
+func (ws *wsConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *wsConn) SetDeadline(t time.Time) error {
+	return ws.conn.SetDeadline(t)
+}
+
+func (ws *wsConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *wsConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
@@ -2,12 +2,35 @@ package view
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+var inputOnce sync.Once
+var inputLines chan string
+
+/*
+Lines typed with this prefix are chat messages, they are handed to the chat 
+handler instead of the prompt that is waiting for input.
+*/
+const CHAT_PREFIX = "/say "
+
+/*
+Lines starting with the command prefix are host commands, such as /kick Bob.
+*/
+const COMMAND_PREFIX = "/"
+
+var chatLock sync.Mutex
+var chatHandler func(text string)
+var commandHandler func(command string)
+
 /*
 Attempt to clear the terminal screen, if the OS is unsupported returns an error.
 */
@@ -32,22 +55,42 @@ func clear() error {
 }
 
 /*
-Return an input scanner.
+Return an input scanner. Every scanner reads from the shared terminal 
+lines, so menus and game prompts never compete for the terminal.
 */
 func Terminal() bufio.Scanner {
-	terminal := bufio.NewReader(os.Stdin)
-	scanner := bufio.NewScanner(terminal)
+	scanner := bufio.NewScanner(new(terminalReader))
 
 	return *scanner
 }
 
+/*
+Reads the shared terminal lines as a stream, one line per read.
+*/
+type terminalReader struct {
+	rest []byte
+}
+
+func (r *terminalReader) Read(p []byte) (int, error) {
+	if len(r.rest) == 0 {
+		line, ok := <-terminalLines()
+		if !ok {
+			return 0, io.EOF
+		}
+		r.rest = []byte(line + "\n")
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
 /*
 Displays a greeting message that is set locally.
 The message should display all available options
 and take the corresponding action on valid input.
 */
 func Greeting(scanner bufio.Scanner)  {
-	var GREETING string = "Hello, do you want to play a game. \n 1) Play bots\n 2) Host game\n 3) Join game\n 4) Exit"
+	var GREETING string = "Hello, do you want to play a game. \n 1) Play bots\n 2) Host game\n 3) Join game\n 4) Join a lobby server\n 5) Exit"
 	err := clear()
 	if err != nil {
 		fmt.Println(err)
@@ -97,10 +140,327 @@ func OnlinePlayers(terminal bufio.Scanner) int {
 	return int(onlinePlayers)
 }
 
+/*
+Prompt the user for the connection type, returns "websocket" or "tcp". An 
+empty input keeps the default connection type.
+*/
+func ChooseTransport(terminal bufio.Scanner, defaultTransport string) string {
+	fmt.Println("Select connection type (leave empty for " + defaultTransport + "):\n 1) WebSocket\n 2) TCP")
+	for terminal.Scan() {
+		input := terminal.Text()
+		if input == "" {
+			return defaultTransport
+		} else if input == "1" {
+			return "websocket"
+		} else if input == "2" {
+			return "tcp"
+		}
+		fmt.Println("Please select one of the options")
+	}
+	return defaultTransport
+}
+
+/*
+Prompt the user whether to encrypt the connection with TLS, an empty input 
+keeps the default.
+*/
+func ChooseTLS(terminal bufio.Scanner, defaultTLS bool) bool {
+	defaultOption := "unencrypted"
+	if defaultTLS {
+		defaultOption = "TLS"
+	}
+	fmt.Println("Encrypt the connection (leave empty for " + defaultOption + "):\n 1) TLS\n 2) Unencrypted")
+	for terminal.Scan() {
+		switch terminal.Text() {
+		case "":
+			return defaultTLS
+		case "1":
+			return true
+		case "2":
+			return false
+		}
+		fmt.Println("Please select one of the options")
+	}
+	return defaultTLS
+}
+
+/*
+Prompt the user for the certificate fingerprint shown by the host, an empty 
+input keeps the default. Without a fingerprint the certificate has to be 
+signed by a trusted certificate authority.
+*/
+func CertificatePin(terminal bufio.Scanner, defaultPin string) string {
+	if defaultPin == "" {
+		fmt.Println("Certificate fingerprint shown by the host (leave empty to trust certificate authorities):")
+	} else {
+		fmt.Println("Certificate fingerprint shown by the host (leave empty for " + defaultPin + "):")
+	}
+	terminal.Scan()
+	pin := terminal.Text()
+	if pin == "" {
+		return defaultPin
+	}
+	return pin
+}
+
+/*
+Prompt the host for who may join the game, returns "open", "password" or 
+"invite". An empty input keeps the default.
+*/
+func ChooseAccess(terminal bufio.Scanner, defaultAccess string) string {
+	fmt.Println("Who may join (leave empty for " + defaultAccess + "):\n 1) Anyone (open)\n 2) Players with a password (password)\n 3) Players with a generated invite code (invite)")
+	for terminal.Scan() {
+		switch terminal.Text() {
+		case "":
+			return defaultAccess
+		case "1":
+			return "open"
+		case "2":
+			return "password"
+		case "3":
+			return "invite"
+		}
+		fmt.Println("Please select one of the options")
+	}
+	return defaultAccess
+}
+
+/*
+Prompt the host for the password of the game, an empty input keeps the 
+default if there is one.
+*/
+func GamePassword(terminal bufio.Scanner, defaultPassword string) string {
+	if defaultPassword == "" {
+		fmt.Println("Password players have to enter to join:")
+	} else {
+		fmt.Println("Password players have to enter to join (leave empty to keep the configured password):")
+	}
+	for terminal.Scan() {
+		password := terminal.Text()
+		if password != "" {
+			return password
+		}
+		if defaultPassword != "" {
+			return defaultPassword
+		}
+		fmt.Println("The password can not be empty")
+	}
+	return defaultPassword
+}
+
+/*
+Prompt the joining player for the password or invite code of the game, the 
+reason the host gave for asking is displayed first.
+*/
+func JoinPassword(terminal bufio.Scanner, reason string) string {
+	fmt.Println("The host rejected the join:", reason)
+	fmt.Println("Please enter the password or invite code of the game:")
+	terminal.Scan()
+	return terminal.Text()
+}
+
+/*
+Prompt the user for the address and port to host on, empty inputs keep the 
+defaults.
+*/
+func BindAddress(terminal bufio.Scanner, defaultHost string, defaultPort string) (string, string) {
+	fmt.Println("Address to host on (leave empty for " + defaultHost + ", 0.0.0.0 for all interfaces):")
+	terminal.Scan()
+	host := terminal.Text()
+	if host == "" {
+		host = defaultHost
+	}
+	fmt.Println("Port to host on (leave empty for " + defaultPort + ", 0 for any free port):")
+	for terminal.Scan() {
+		port := terminal.Text()
+		if port == "" {
+			return host, defaultPort
+		}
+		portNumber, parseErr := strconv.ParseInt(port, 10, 64)
+		if parseErr != nil || portNumber < 0 || portNumber > 65535 {
+			fmt.Println("Please enter a port between 0 and 65535")
+			continue
+		}
+		return host, port
+	}
+	return host, defaultPort
+}
+
+/*
+Prompt the user to pick one of the games found on the local network.
+
+Returns the index of the game, or -1 to enter an address instead.
+*/
+func ChooseGame(terminal bufio.Scanner, games []string) int {
+	fmt.Println("Games on the local network:")
+	for i := 0; i < len(games); i++ {
+		fmt.Println(" " + strconv.Itoa(i) + ") " + games[i])
+	}
+	fmt.Println("Select a game (leave empty to enter an address):")
+	for terminal.Scan() {
+		input := terminal.Text()
+		if input == "" {
+			return -1
+		}
+		index, err := strconv.Atoi(input)
+		if err == nil && index >= 0 && index < len(games) {
+			return index
+		}
+		fmt.Println("Please select one of the games")
+	}
+	return -1
+}
+
+/*
+Prompt the user for the host:port of the game to join, an empty input keeps 
+the default.
+*/
+func JoinAddress(terminal bufio.Scanner, defaultAddress string) string {
+	fmt.Println("Host to join as host:port (leave empty for " + defaultAddress + "):")
+	terminal.Scan()
+	address := terminal.Text()
+	if address == "" {
+		return defaultAddress
+	}
+	return address
+}
+
+/*
+Prompt the user for the WebSocket path, an empty input keeps the default path.
+*/
+func WebSocketPath(terminal bufio.Scanner, defaultPath string) string {
+	fmt.Println("WebSocket path (leave empty for " + defaultPath + "):")
+	terminal.Scan()
+	path := terminal.Text()
+	if path == "" {
+		return defaultPath
+	}
+	return path
+}
+
+/*
+Returns the shared channel of lines typed into the terminal. Every prompt 
+and menu reads from this channel, see Terminal, so that a prompt that is 
+abandoned does not leave a second reader competing for the terminal. Chat 
+lines never reach the prompts.
+*/
+func terminalLines() <-chan string {
+	inputOnce.Do(func() {
+		inputLines = make(chan string)
+		go func() {
+			terminal := bufio.NewScanner(os.Stdin)
+			for terminal.Scan() {
+				line := terminal.Text()
+				if strings.HasPrefix(line, CHAT_PREFIX) && handleChat(strings.TrimPrefix(line, CHAT_PREFIX)) {
+					continue
+				}
+				if strings.HasPrefix(line, COMMAND_PREFIX) && handleCommand(strings.TrimPrefix(line, COMMAND_PREFIX)) {
+					continue
+				}
+				inputLines <- line
+			}
+			close(inputLines)
+		}()
+	})
+	return inputLines
+}
+
+/*
+Drops the lines typed while no prompt was waiting, such as a stray enter, 
+so that they are not taken as the answer to the next prompt. Returns the 
+shared channel of lines.
+*/
+func freshLines() <-chan string {
+	drainLines()
+	return terminalLines()
+}
+
+func drainLines() {
+	input := terminalLines()
+	for {
+		select {
+		case _, ok := <-input:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+/*
+Sets the function that sends chat messages typed into the terminal, nil 
+turns chat off.
+*/
+func SetChatHandler(handler func(text string)) {
+	chatLock.Lock()
+	chatHandler = handler
+	chatLock.Unlock()
+	if handler != nil {
+		// start reading the terminal so chat works before the first prompt
+		terminalLines()
+	}
+}
+
+/*
+Hands a chat message to the chat handler.
+
+Returns false if chat is turned off.
+*/
+func handleChat(text string) bool {
+	chatLock.Lock()
+	handler := chatHandler
+	chatLock.Unlock()
+	if handler == nil {
+		return false
+	}
+	handler(text)
+	return true
+}
+
+/*
+Sets the function that runs the host commands typed into the terminal, nil 
+turns the commands off.
+*/
+func SetCommandHandler(handler func(command string)) {
+	chatLock.Lock()
+	commandHandler = handler
+	chatLock.Unlock()
+	if handler != nil {
+		terminalLines()
+	}
+}
+
+/*
+Hands a host command to the command handler.
+
+Returns false if host commands are turned off.
+*/
+func handleCommand(command string) bool {
+	chatLock.Lock()
+	handler := commandHandler
+	chatLock.Unlock()
+	if handler == nil {
+		return false
+	}
+	handler(command)
+	return true
+}
+
+/*
+Displays a chat message.
+*/
+func Chat(from string, sent time.Time, text string) {
+	fmt.Println("[" + sent.Local().Format("15:04") + "] " + from + ": " + text)
+}
+
 /*
 Print out the players hand and current green apple, take which card to play from terminal in the form of an index int.
+
+Returns an error if the context is done before a card is chosen.
 */
-func ChooseCard(greenApple string, hand []string) int {
+func ChooseCard(ctx context.Context, greenApple string, hand []string) (int, error) {
 	clear()
 	fmt.Println("The current green apple is", greenApple)
 	fmt.Println("Please select a card to play:")
@@ -108,30 +468,43 @@ func ChooseCard(greenApple string, hand []string) int {
 		fmt.Println("[", i, "]: ", hand[i])
 	}
 	fmt.Println("Select card by submitting its index:")
-	terminal := Terminal()
-	for terminal.Scan() {
-		input := terminal.Text()
-		choice, convErr := strconv.ParseInt(input, 10, 64)
-		if convErr != nil {
-			fmt.Println("Please only enter the integer representation of your choice.")
-			continue
+	input := freshLines()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Time is up, no card was played.")
+			return 0, ctx.Err()
+		case line, ok := <-input:
+			if !ok {
+				return 0, errors.New("terminal closed")
+			}
+			choice, convErr := strconv.ParseInt(line, 10, 64)
+			if convErr != nil {
+				fmt.Println("Please only enter the integer representation of your choice.")
+				continue
+			}
+			if choice < 0 || int(choice) >= len(hand) {
+				fmt.Println("Please select a valid option")
+				continue
+			}
+			WaitPlayerCards()
+			return int(choice), nil
 		}
-		return int(choice)
 	}
-	WaitPlayerCards()
-	return 0
 }
 
 func WaitPlayerCards() {
 	clear()
-	fmt.Println("Waiting for players to submit cards...")
+	fmt.Println("Waiting for players to submit cards, type " + CHAT_PREFIX + "and a message to chat...")
 }
 
 /*
 Print out the submitted red apples and current green apple, take the winning red apples index from terminal in the 
 form of an index int.
+
+Returns an error if the context is done before a winner is chosen.
 */
-func JudgeCards(greenApple string, redApples []string) int {
+func JudgeCards(ctx context.Context, greenApple string, redApples []string) (int, error) {
 	clear()
 	fmt.Println("Current green apple", greenApple)
 	fmt.Println("Submitted red apples")
@@ -140,23 +513,29 @@ func JudgeCards(greenApple string, redApples []string) int {
 	}
 	fmt.Println("Select winning card by submitting its index:")
 
-	terminal := Terminal()
-	var choice int
-	for terminal.Scan() {
-		input := terminal.Text()
-		parseChoice, convErr := strconv.ParseInt(input, 10, 64)
-		if convErr != nil {
-			fmt.Println("Please enter a valid option.")
-			continue
-		}
-		choice = int(parseChoice)
-		if choice < 0 || choice >= len(redApples) {
-			fmt.Println("Please select a valid option")
-		} else {
-			break
+	input := freshLines()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Time is up, no winner was chosen.")
+			return 0, ctx.Err()
+		case line, ok := <-input:
+			if !ok {
+				return 0, errors.New("terminal closed")
+			}
+			parseChoice, convErr := strconv.ParseInt(line, 10, 64)
+			if convErr != nil {
+				fmt.Println("Please enter a valid option.")
+				continue
+			}
+			choice := int(parseChoice)
+			if choice < 0 || choice >= len(redApples) {
+				fmt.Println("Please select a valid option")
+				continue
+			}
+			return choice, nil
 		}
 	}
-	return choice
 }
 
 /*
@@ -190,24 +569,100 @@ func ScoreBoard(score []string) {
 }
 
 /*
-Displays the incomming message and returns the users input.
+Displays the incomming message and returns the users input. If the host 
+rejected the previous answer the reason is displayed first.
 
-If the scan loop is somehow broken, returns default value 0.
+Returns an error if the context is done, when the host cancels the prompt, 
+before a valid option is entered.
 */
-func OnlinePlay(validInputLimit int, display []string) string {
+func OnlinePlay(ctx context.Context, validInputLimit int, display []string, rejection string) (string, error) {
+	if rejection != "" {
+		fmt.Println("Your choice was rejected:", rejection)
+	}
 	for i := 0; i < len(display); i++ {
 		fmt.Println(display[i])
 	}
-	terminal := Terminal()
+	input := freshLines()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case inputStr, ok := <-input:
+			if !ok {
+				return "", errors.New("terminal closed")
+			}
+			input64, parseErr := strconv.ParseInt(inputStr, 10, 64)
+			var choice int = int(input64)
+			if parseErr == nil && !(choice < 0) && choice < validInputLimit {
+				return inputStr, nil
+			}
+			fmt.Println("Please select a valid option")
+		}
+	}
+}
+
+/*
+Displays a round received from the host followed by the cards to choose 
+from, and returns the index of the card the user picks. If the host 
+rejected the previous answer the reason is displayed first.
+
+Returns an error if the context is done, when the host cancels the prompt, 
+before a card is picked.
+*/
+func OnlineCards(ctx context.Context, heading []string, cards []string, rejection string) (int, error) {
+	if rejection != "" {
+		fmt.Println("Your choice was rejected:", rejection)
+	}
+	for i := 0; i < len(heading); i++ {
+		fmt.Println(heading[i])
+	}
+	for i := 0; i < len(cards); i++ {
+		fmt.Println("[", i, "]", cards[i])
+	}
+	input := freshLines()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case line, ok := <-input:
+			if !ok {
+				return 0, errors.New("terminal closed")
+			}
+			choice, parseErr := strconv.Atoi(line)
+			if parseErr == nil && choice >= 0 && choice < len(cards) {
+				return choice, nil
+			}
+			fmt.Println("Please select a valid option")
+		}
+	}
+}
+
+/*
+Displays an announcement from the game.
+*/
+func Announce(info string) {
+	fmt.Println(info)
+}
+
+/*
+Prompt the user for a time limit in seconds, an empty input keeps the default 
+and zero means no limit.
+*/
+func TimeLimit(terminal bufio.Scanner, label string, defaultSeconds int) time.Duration {
+	fmt.Println("Seconds for " + label + " (leave empty for " + fmt.Sprint(defaultSeconds) + ", 0 for no limit):")
 	for terminal.Scan() {
-		inputStr := terminal.Text()
-		input64, _ := strconv.ParseInt(inputStr, 10, 64)
-		var input int = int(input64)
-		if !(input < 0) && input < validInputLimit {
-			return inputStr
+		input := terminal.Text()
+		if input == "" {
+			return time.Duration(defaultSeconds) * time.Second
+		}
+		seconds, parseErr := strconv.ParseInt(input, 10, 64)
+		if parseErr != nil || seconds < 0 {
+			fmt.Println("Please enter a positive integer")
+			continue
 		}
+		return time.Duration(seconds) * time.Second
 	}
-	return "0"
+	return time.Duration(defaultSeconds) * time.Second
 }
 
 /*
@@ -217,4 +672,134 @@ func OnlineDisplay(display []string) {
 	for i := 0; i < len(display); i++ {
 		fmt.Println(display[i])
 	}
-}
\ No newline at end of file
+}
+/*
+Reads the next line typed into the terminal.
+
+Returns an error if the terminal is closed.
+*/
+func readLine() (string, error) {
+	line, ok := <-terminalLines()
+	if !ok {
+		return "", errors.New("terminal closed")
+	}
+	return line, nil
+}
+
+/*
+Prompt the user to join the game as a player or a spectator, returns true 
+for a spectator.
+*/
+func JoinAs(terminal bufio.Scanner) bool {
+	fmt.Println("Join as:\n 1) Player\n 2) Spectator")
+	for terminal.Scan() {
+		switch terminal.Text() {
+		case "1", "":
+			return false
+		case "2":
+			return true
+		}
+		fmt.Println("Please select one of the options")
+	}
+	return false
+}
+
+/*
+Shows the rooms of a lobby server and prompts the user for what to do next. 
+The action is "enter" or "watch" together with the index of the chosen room, 
+or one of "create", "refresh" and "quit".
+*/
+func LobbyMenu(rooms []string) (string, int) {
+	clear()
+	if len(rooms) == 0 {
+		fmt.Println("There are no open rooms.")
+	}
+	for i := 0; i < len(rooms); i++ {
+		fmt.Println("[", i, "]: ", rooms[i])
+	}
+	fmt.Println("Enter the index of a room to enter it, w and an index to watch it, c to create a room, r to refresh or q to quit:")
+	for {
+		input, err := readLine()
+		if err != nil {
+			return "quit", -1
+		}
+		switch input {
+		case "c":
+			return "create", -1
+		case "r", "":
+			return "refresh", -1
+		case "q":
+			return "quit", -1
+		}
+		action := "enter"
+		if strings.HasPrefix(input, "w") {
+			action = "watch"
+			input = strings.TrimSpace(strings.TrimPrefix(input, "w"))
+		}
+		index, parseErr := strconv.ParseInt(input, 10, 64)
+		if parseErr == nil && index >= 0 && int(index) < len(rooms) {
+			return action, int(index)
+		}
+		fmt.Println("Please select one of the options")
+	}
+}
+
+/*
+Prompt the user for the name of a new room and how many online players it 
+waits for, at most maxPlayers.
+*/
+func RoomSettings(maxPlayers int) (string, int) {
+	fmt.Println("Name of the room:")
+	name, _ := readLine()
+	fmt.Println("How many online players, between 1 and " + fmt.Sprint(maxPlayers) + "?")
+	for {
+		input, err := readLine()
+		if err != nil {
+			return name, 1
+		}
+		players, parseErr := strconv.ParseInt(input, 10, 64)
+		if parseErr == nil && players >= 1 && int(players) <= maxPlayers {
+			return name, int(players)
+		}
+		fmt.Println("Please enter an integer between 1 and " + fmt.Sprint(maxPlayers))
+	}
+}
+
+/*
+Prompt the user for the name to join a lobby room under, if the room 
+rejected the previous name the reason is displayed first.
+*/
+func LobbyName(rejection string) string {
+	if rejection != "" {
+		fmt.Println("The room rejected the name:", rejection)
+	}
+	fmt.Println("Please enter player name:")
+	name, _ := readLine()
+	return name
+}
+
+/*
+Waits for the user to type one of the commands while no prompt is shown.
+
+Returns the command once it is typed, or an error if the context is done 
+first.
+*/
+func WaitForCommand(ctx context.Context, commands []string) (string, error) {
+	input := terminalLines()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case line, ok := <-input:
+			if !ok {
+				return "", errors.New("terminal closed")
+			}
+			for i := 0; i < len(commands); i++ {
+				if line == commands[i] {
+					return line, nil
+				}
+			}
+			fmt.Println("You can type:", strings.Join(commands, ", "))
+		}
+	}
+}